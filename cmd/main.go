@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"strings"
+	"time"
+	// Station time zones must resolve even on images without zoneinfo
+	_ "time/tzdata"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -34,16 +38,101 @@ func main() {
 	stationRepo := repository.NewStationRepository(db)
 	airQualityRepo := repository.NewAirQualityRepository(db)
 	categoryRepo := repository.NewCategoryRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	qualityEventRepo := repository.NewQualityEventRepository(db)
+	rollupRepo := repository.NewRollupRepository(db)
+	alertRepo := repository.NewAlertRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
 
 	// Initialize services
-	stationService := service.NewStationService(stationRepo, redisClient)
-	airQualityService := service.NewAirQualityService(airQualityRepo, stationRepo, redisClient)
-	dashboardService := service.NewDashboardService(stationRepo, airQualityRepo, categoryRepo, redisClient)
+	rollupService := service.NewRollupService(rollupRepo, airQualityRepo)
+	stationService := service.NewStationService(stationRepo, rollupService)
+	eventBroker := service.NewEventBroker(redisClient)
+	webhookService := service.NewWebhookService(
+		webhookRepo,
+		config.EnvDuration("WEBHOOK_POLL_INTERVAL", 5*time.Second),
+		config.EnvDuration("WEBHOOK_RETENTION", 7*24*time.Hour),
+	)
+	eventBroker.SetOutbox(webhookService)
+	var mailer service.Mailer
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		smtpMailer, err := service.NewSMTPMailer(
+			host,
+			config.EnvInt("SMTP_PORT", 587),
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+			os.Getenv("SMTP_FROM"),
+			config.EnvDuration("SMTP_TIMEOUT", 30*time.Second),
+		)
+		if err != nil {
+			log.Fatalf("Invalid SMTP configuration: %v", err)
+		}
+		mailer = smtpMailer
+	}
+	notificationService := service.NewNotificationService(
+		notificationRepo,
+		stationRepo,
+		airQualityRepo,
+		categoryRepo,
+		mailer,
+		config.EnvDuration("EMAIL_ALERT_COOLDOWN", time.Hour),
+		config.EnvInt("EMAIL_MAX_PER_HOUR", 10),
+		config.EnvInt("EMAIL_DIGEST_HOUR", 7),
+	)
+	eventBroker.AddSink(notificationService)
+	alertService := service.NewAlertService(alertRepo, stationRepo, categoryRepo, eventBroker)
+	airQualityService := service.NewAirQualityService(airQualityRepo, stationRepo, categoryRepo, rollupService, eventBroker, alertService, redisClient)
+	dashboardService := service.NewDashboardService(stationRepo, airQualityRepo, categoryRepo, rollupService, redisClient)
+	dailyISPUService := service.NewDailyISPUService(rollupRepo, stationRepo, categoryRepo)
+	exportService := service.NewExportService(airQualityRepo, categoryRepo)
+	heatmapService := service.NewHeatmapService(
+		airQualityRepo,
+		categoryRepo,
+		redisClient,
+		config.EnvDuration("HEATMAP_CACHE_TTL", 5*time.Minute),
+		config.EnvDuration("HEATMAP_MAX_SAMPLE_AGE", 3*time.Hour),
+	)
+	anomalyService := service.NewAnomalyDetectionService(
+		airQualityRepo,
+		qualityEventRepo,
+		config.EnvDuration("QC_SCAN_INTERVAL", 15*time.Minute),
+		config.EnvDuration("QC_SCAN_WINDOW", 24*time.Hour),
+	)
+
+	stationMonitorService := service.NewStationMonitorService(
+		stationRepo,
+		airQualityRepo,
+		eventBroker,
+		redisClient,
+		config.EnvDuration("STATION_MONITOR_INTERVAL", time.Minute),
+	)
+
+	// Start background jobs
+	ctx := context.Background()
+	go anomalyService.Run(ctx)
+	go rollupService.EnsureBuilt()
+	go eventBroker.Run(ctx)
+	go webhookService.Run(ctx)
+	go notificationService.Run(ctx)
+	go stationMonitorService.Run(ctx)
+	go middleware.PruneIdempotencyKeys(ctx, idempotencyRepo)
 
 	// Initialize handlers
 	stationHandler := handler.NewStationHandler(stationService, dashboardService)
-	airQualityHandler := handler.NewAirQualityHandler(airQualityService)
+	airQualityHandler := handler.NewAirQualityHandler(airQualityService, stationService)
 	dashboardHandler := handler.NewDashboardHandler(dashboardService)
+	qualityHandler := handler.NewQualityHandler(anomalyService)
+	rollupHandler := handler.NewRollupHandler(rollupService, stationService)
+	dailyISPUHandler := handler.NewDailyISPUHandler(dailyISPUService)
+	mapHandler := handler.NewMapHandler(heatmapService)
+	exportHandler := handler.NewExportHandler(exportService, stationService)
+	streamHandler := handler.NewStreamHandler(eventBroker)
+	alertHandler := handler.NewAlertHandler(alertService)
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+	notificationHandler := handler.NewNotificationHandler(notificationService)
+	allowedOrigins := strings.Split(os.Getenv("ALLOWED_ORIGINS"), ",")
+	webSocketHandler := handler.NewWebSocketHandler(eventBroker, dashboardService, allowedOrigins)
 
 	// Initialize Gin router
 	r := gin.Default()
@@ -51,10 +140,10 @@ func main() {
 	// CORS configuration
 	// CORS configuration
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     strings.Split(os.Getenv("ALLOWED_ORIGINS"), ","),
+		AllowOrigins:     allowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Disposition"},
 		AllowCredentials: true,
 	}))
 
@@ -72,8 +161,10 @@ func main() {
 		stations := api.Group("/stations")
 		{
 			stations.GET("", stationHandler.GetAllStations)
+			stations.GET("/nearby", stationHandler.GetNearbyStations)
 			stations.GET("/:id", stationHandler.GetStationByID)
 			stations.GET("/:id/latest", stationHandler.GetStationLatestData)
+			stations.GET("/:id/status-history", stationHandler.GetStationStatusHistory)
 			stations.POST("", stationHandler.CreateStation)
 			stations.PUT("/:id", stationHandler.UpdateStation)
 			stations.DELETE("/:id", stationHandler.DeleteStation)
@@ -84,7 +175,12 @@ func main() {
 		{
 			airQuality.GET("/latest", airQualityHandler.GetLatestData)
 			airQuality.GET("/station/:id", airQualityHandler.GetStationHistory)
-			airQuality.POST("", airQualityHandler.InsertAirQuality)
+			airQuality.GET("/station/:id/aggregates", rollupHandler.GetAggregates)
+			airQuality.GET("/station/:id/daily-ispu", dailyISPUHandler.GetStationDailyISPU)
+			airQuality.GET("/daily-summary", dailyISPUHandler.GetNationalSummary)
+			airQuality.GET("/export", exportHandler.Export)
+			airQuality.POST("", middleware.Idempotency(idempotencyRepo), airQualityHandler.InsertAirQuality)
+			airQuality.POST("/batch", middleware.Idempotency(idempotencyRepo), airQualityHandler.InsertAirQualityBatch)
 		}
 
 		// Dashboard endpoints
@@ -92,14 +188,69 @@ func main() {
 		{
 			dashboard.GET("/overview", dashboardHandler.GetOverview)
 			dashboard.GET("/statistics", dashboardHandler.GetStatistics)
+			dashboard.GET("/availability", dashboardHandler.GetDataAvailability)
+			dashboard.GET("/provinces", dashboardHandler.GetProvinces)
+			dashboard.GET("/provinces/:province", dashboardHandler.GetProvinceDetail)
 		}
 
 		// Map endpoints
 		maps := api.Group("/map")
 		{
 			maps.GET("/stations", stationHandler.GetMapStations)
+			maps.GET("/heatmap", mapHandler.GetHeatmap)
+			maps.GET("/tiles/:z/:x/:y", mapHandler.GetTile)
+		}
+
+		// Data quality endpoints
+		quality := api.Group("/quality")
+		{
+			quality.GET("/events", qualityHandler.GetEvents)
+			quality.POST("/events/:id/acknowledge", qualityHandler.AcknowledgeEvent)
+			quality.POST("/scan", qualityHandler.Scan)
+		}
+
+		// Alert endpoints
+		alerts := api.Group("/alerts")
+		{
+			alerts.GET("", alertHandler.GetAlerts)
+			alerts.GET("/:id", alertHandler.GetAlert)
+			alerts.GET("/rules", alertHandler.GetRules)
+			alerts.GET("/rules/:id", alertHandler.GetRule)
+			alerts.POST("/rules", alertHandler.CreateRule)
+			alerts.PUT("/rules/:id", alertHandler.UpdateRule)
+			alerts.DELETE("/rules/:id", alertHandler.DeleteRule)
 		}
 
+		// Webhook endpoints
+		webhooks := api.Group("/webhooks")
+		{
+			webhooks.GET("", webhookHandler.GetWebhooks)
+			webhooks.GET("/:id", webhookHandler.GetWebhook)
+			webhooks.GET("/:id/deliveries", webhookHandler.GetDeliveries)
+			webhooks.POST("", webhookHandler.CreateWebhook)
+			webhooks.PUT("/:id", webhookHandler.UpdateWebhook)
+			webhooks.DELETE("/:id", webhookHandler.DeleteWebhook)
+		}
+
+		// Email notification endpoints
+		notifications := api.Group("/notifications")
+		{
+			notifications.GET("/subscribers", notificationHandler.GetSubscribers)
+			notifications.GET("/subscribers/:id", notificationHandler.GetSubscriber)
+			notifications.POST("/subscribers", notificationHandler.CreateSubscriber)
+			notifications.PUT("/subscribers/:id", notificationHandler.UpdateSubscriber)
+			notifications.DELETE("/subscribers/:id", notificationHandler.DeleteSubscriber)
+			notifications.GET("/log", notificationHandler.GetLog)
+			notifications.POST("/digest", notificationHandler.SendDigest)
+		}
+
+		// Live updates
+		api.GET("/stream", streamHandler.Stream)
+		api.GET("/ws", webSocketHandler.Connect)
+
+		// Rollup maintenance
+		api.POST("/rollups/rebuild", rollupHandler.Rebuild)
+
 		// Categories
 		api.GET("/categories", dashboardHandler.GetCategories)
 	}
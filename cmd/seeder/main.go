@@ -7,6 +7,7 @@ import (
 
 	"github.com/ispu-monitoring/backend/internal/config"
 	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/service"
 	"github.com/joho/godotenv"
 	"gorm.io/gorm"
 )
@@ -67,6 +68,7 @@ func seedStations(db *gorm.DB) {
 		var existingStation model.Station
 		if err := db.Where("code = ?", station.Code).First(&existingStation).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
+				station.Timezone = model.TimezoneFor(station.Province, station.Longitude)
 				if err := db.Create(&station).Error; err != nil {
 					log.Printf("Failed to create station %s: %v", station.Name, err)
 				} else {
@@ -88,87 +90,55 @@ func seedAirQuality(db *gorm.DB) {
 		return
 	}
 
-	// Define ISPU category ranges for better distribution
-	ispuCategories := []struct {
-		min int
-		max int
+	// PM2.5 concentration ranges (µg/m³) spanning each ISPU category, so stations
+	// spread over the categories once the ISPU is computed
+	pm25Ranges := []struct {
+		category string
+		min      float64
+		max      float64
 	}{
-		{0, 50},    // Baik
-		{51, 100},  // Sedang
-		{101, 200}, // Tidak Sehat
-		{201, 300}, // Sangat Tidak Sehat
-		{301, 400}, // Berbahaya
+		{"Baik", 5, 15},
+		{"Sedang", 16, 55},
+		{"Tidak Sehat", 56, 150},
+		{"Sangat Tidak Sehat", 151, 250},
+		{"Berbahaya", 251, 400},
 	}
 
 	for stationIndex, station := range stations {
 		// Vary ISPU category for different stations for better testing
-		categoryIndex := stationIndex % len(ispuCategories)
-		selectedCategory := ispuCategories[categoryIndex]
+		selectedRange := pm25Ranges[stationIndex%len(pm25Ranges)]
 
 		// Generate 24 hours of data
 		for i := 0; i < 24; i++ {
 			timestamp := time.Now().Add(time.Duration(-i) * time.Hour)
-			
+
 			// Check if data already exists for this hour
 			var count int64
 			db.Model(&model.AirQuality{}).
-				Where("station_id = ? AND timestamp BETWEEN ? AND ?", 
-					station.ID, 
-					timestamp.Truncate(time.Hour), 
+				Where("station_id = ? AND timestamp BETWEEN ? AND ?",
+					station.ID,
+					timestamp.Truncate(time.Hour),
 					timestamp.Truncate(time.Hour).Add(time.Hour)).
 				Count(&count)
-				
+
 			if count > 0 {
 				continue
 			}
 
-			var ispu int
-			var pm25, pm10, co, no2, o3, so2, hc float64
-
-			// Generate realistic values based on ISPU category
-			// Add some variation within the category
-			ispu = selectedCategory.min + rand.Intn(selectedCategory.max-selectedCategory.min+1)
-			
-			// Vary values with small random fluctuations for hourly changes
-			baseVariation := float64(i) * 0.5
-			
-			// Scale pollutant values based on ISPU value
-			ispuFactor := float64(ispu) / 100.0
-			
-			pm25 = (10.0 + ispuFactor*20.0) + rand.Float64()*5 - 2.5 + baseVariation
-			pm10 = (15.0 + ispuFactor*35.0) + rand.Float64()*10 - 5 + baseVariation
-			so2 = (2.0 + ispuFactor*15.0) + rand.Float64()*3 - 1.5
-			co = (1.0 + ispuFactor*5.0) + rand.Float64()*2 - 1
-			o3 = (15.0 + ispuFactor*40.0) + rand.Float64()*10 - 5
-			no2 = (8.0 + ispuFactor*25.0) + rand.Float64()*5 - 2.5
-			hc = (0.5 + ispuFactor*2.0) + rand.Float64()*0.5 - 0.25
-
-			// Ensure no negative values
-			if pm25 < 0 {
-				pm25 = 0
-			}
-			if pm10 < 0 {
-				pm10 = 0
-			}
-			if so2 < 0 {
-				so2 = 0
-			}
-			if co < 0 {
-				co = 0
-			}
-			if o3 < 0 {
-				o3 = 0
-			}
-			if no2 < 0 {
-				no2 = 0
-			}
-			if hc < 0 {
-				hc = 0
-			}
+			// Generate realistic concentrations in µg/m³, with PM2.5 within the
+			// station's range and the other pollutants scaled along with it
+			pm25 := selectedRange.min + rand.Float64()*(selectedRange.max-selectedRange.min)
+			level := pm25 / 100.0
+
+			pm10 := pm25 * (1.4 + rand.Float64()*0.4)
+			so2 := 5.0 + level*30.0 + rand.Float64()*5
+			co := 500.0 + level*2500.0 + rand.Float64()*500
+			o3 := 20.0 + level*60.0 + rand.Float64()*20
+			no2 := 10.0 + level*50.0 + rand.Float64()*10
+			hc := 10.0 + level*20.0 + rand.Float64()*5
 
 			aq := model.AirQuality{
 				StationID: station.ID,
-				ISPU:      ispu,
 				PM25:      &pm25,
 				PM10:      &pm10,
 				CO:        &co,
@@ -178,12 +148,13 @@ func seedAirQuality(db *gorm.DB) {
 				HC:        &hc,
 				Timestamp: timestamp,
 			}
+			// Scored the same way as ingested readings
+			service.ScoreAirQuality(&aq)
 
 			if err := db.Create(&aq).Error; err != nil {
 				log.Printf("Failed to create air quality data for station %s: %v", station.Name, err)
 			}
 		}
-		log.Printf("Seeded air quality data for station: %s (ISPU range: %d-%d)", 
-			station.Name, selectedCategory.min, selectedCategory.max)
+		log.Printf("Seeded air quality data for station: %s (%s)", station.Name, selectedRange.category)
 	}
 }
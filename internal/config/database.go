@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/ispu-monitoring/backend/internal/model"
 	"gorm.io/driver/postgres"
@@ -28,7 +29,8 @@ func InitDB() (*gorm.DB, error) {
 	}
 
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger:         logger.Default.LogMode(logger.Info),
+		TranslateError: true,
 	})
 
 	if err != nil {
@@ -38,16 +40,53 @@ func InitDB() (*gorm.DB, error) {
 	// Only run migrations if explicitly enabled (to avoid slow startup on serverless)
 	if os.Getenv("RUN_MIGRATIONS") == "true" {
 		log.Println("Running database migrations...")
+
+		// The (station_id, timestamp) unique index cannot be built while duplicates exist
+		if err := removeDuplicateReadings(db); err != nil {
+			return nil, fmt.Errorf("failed to remove duplicate readings: %w", err)
+		}
+
+		// Likewise the index allowing one open alert per rule and station
+		if err := resolveDuplicateOpenAlerts(db); err != nil {
+			return nil, fmt.Errorf("failed to resolve duplicate open alerts: %w", err)
+		}
+
+		// Existing stations need a time zone once the column is introduced
+		backfillTimezones := db.Migrator().HasTable(&model.Station{}) &&
+			!db.Migrator().HasColumn(&model.Station{}, "Timezone")
+
 		err = db.AutoMigrate(
 			&model.Station{},
 			&model.AirQuality{},
 			&model.ISPUCategory{},
+			&model.IdempotencyKey{},
+			&model.QualityEvent{},
+			&model.HourlyAggregate{},
+			&model.DailyAggregate{},
+			&model.AlertRule{},
+			&model.Alert{},
+			&model.AlertTransition{},
+			&model.Webhook{},
+			&model.WebhookDelivery{},
+			&model.NotificationSubscriber{},
+			&model.NotificationLog{},
+			&model.StationStatusTransition{},
 		)
 
 		if err != nil {
 			return nil, fmt.Errorf("failed to migrate database: %w", err)
 		}
 
+		if backfillTimezones {
+			if err := backfillStationTimezones(db); err != nil {
+				return nil, fmt.Errorf("failed to backfill station time zones: %w", err)
+			}
+		}
+
+		if err := clearUnscoredISPU(db); err != nil {
+			return nil, fmt.Errorf("failed to clear ISPU of invalid readings: %w", err)
+		}
+
 		log.Println("Database migrated successfully")
 
 		// Seed categories if empty
@@ -79,6 +118,114 @@ func SeedCategories(db *gorm.DB) {
 	log.Println("Categories seeded successfully")
 }
 
+// removeDuplicateReadings keeps only the first stored reading for each station and
+// timestamp. Deleting readings needs an explicit DEDUPE_READINGS=true; without it
+// the migration stops when duplicates exist. Every dropped id is logged.
+func removeDuplicateReadings(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&model.AirQuality{}) {
+		return nil
+	}
+
+	var duplicates int64
+	err := db.Raw(`
+		SELECT COUNT(*) FROM air_qualities a
+		WHERE EXISTS (
+			SELECT 1 FROM air_qualities b
+			WHERE a.station_id = b.station_id
+				AND a.timestamp = b.timestamp
+				AND a.id > b.id
+		)
+	`).Scan(&duplicates).Error
+	if err != nil || duplicates == 0 {
+		return err
+	}
+	if os.Getenv("DEDUPE_READINGS") != "true" {
+		return fmt.Errorf("found %d duplicate air quality readings; set DEDUPE_READINGS=true to delete them", duplicates)
+	}
+
+	var removed []uint
+	err = db.Raw(`
+		DELETE FROM air_qualities a
+		USING air_qualities b
+		WHERE a.station_id = b.station_id
+			AND a.timestamp = b.timestamp
+			AND a.id > b.id
+		RETURNING a.id
+	`).Scan(&removed).Error
+	if err != nil {
+		return err
+	}
+	log.Printf("Removed %d duplicate air quality readings: ids %v", len(removed), removed)
+	return nil
+}
+
+// resolveDuplicateOpenAlerts keeps the oldest pending or firing alert of each rule
+// and station open and resolves the others
+func resolveDuplicateOpenAlerts(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&model.Alert{}) {
+		return nil
+	}
+
+	result := db.Exec(`
+		UPDATE alerts a
+		SET state = ?, resolved_at = NOW(), updated_at = NOW()
+		FROM alerts b
+		WHERE a.rule_id = b.rule_id
+			AND a.station_id = b.station_id
+			AND a.state <> ? AND b.state <> ?
+			AND a.id > b.id
+	`, model.AlertResolved, model.AlertResolved, model.AlertResolved)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Resolved %d duplicate open alerts", result.RowsAffected)
+	}
+	return nil
+}
+
+// backfillStationTimezones derives each station's time zone from its province and
+// drops the daily rollup, which was bucketed in UTC, so it is rebuilt in local days
+func backfillStationTimezones(db *gorm.DB) error {
+	var stations []model.Station
+	if err := db.Find(&stations).Error; err != nil {
+		return err
+	}
+
+	for _, station := range stations {
+		timezone := model.TimezoneFor(station.Province, station.Longitude)
+		if err := db.Model(&model.Station{}).Where("id = ?", station.ID).Update("timezone", timezone).Error; err != nil {
+			return err
+		}
+	}
+	log.Printf("Assigned time zones to %d stations", len(stations))
+
+	return db.Exec("DELETE FROM air_quality_daily").Error
+}
+
+// clearUnscoredISPU nulls the ISPU of readings stored with 0 although quality
+// control flagged every reported pollutant invalid. Readings stored before quality
+// control also lack a critical pollutant, so only the flags single them out.
+func clearUnscoredISPU(db *gorm.DB) error {
+	conditions := []string{
+		"ispu IS NOT NULL",
+		"COALESCE(critical_pollutant, '') = ''",
+		"qc_status = 'invalid'",
+	}
+	for _, pollutant := range model.Pollutants {
+		conditions = append(conditions, fmt.Sprintf("(%[1]s IS NULL OR qc_%[1]s = 'invalid')", pollutant))
+	}
+
+	result := db.Exec("UPDATE air_qualities SET ispu = NULL WHERE " + strings.Join(conditions, " AND "))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Cleared the ISPU of %d readings without a valid pollutant", result.RowsAffected)
+	}
+	return nil
+}
+
 func ptrInt(i int) *int {
 	return &i
 }
@@ -1,26 +1,46 @@
 package handler
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/ispu-monitoring/backend/internal/model"
 	"github.com/ispu-monitoring/backend/internal/service"
 )
 
 type AirQualityHandler struct {
-	service *service.AirQualityService
+	service        *service.AirQualityService
+	stationService *service.StationService
 }
 
-func NewAirQualityHandler(service *service.AirQualityService) *AirQualityHandler {
-	return &AirQualityHandler{service: service}
+func NewAirQualityHandler(service *service.AirQualityService, stationService *service.StationService) *AirQualityHandler {
+	return &AirQualityHandler{
+		service:        service,
+		stationService: stationService,
+	}
 }
 
 // GetLatestData handles GET /api/v1/air-quality/latest
 func (h *AirQualityHandler) GetLatestData(c *gin.Context) {
-	data, err := h.service.GetLatestData()
+	qc, ok := parseQCFilter(c)
+	if !ok {
+		return
+	}
+
+	page, ok := parsePageRequest(c, 0, maxStationLimit)
+	if !ok {
+		return
+	}
+
+	data, pagination, err := h.service.GetLatestDataPage(qc, page)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.APIResponse{
 			Success: false,
@@ -32,14 +52,15 @@ func (h *AirQualityHandler) GetLatestData(c *gin.Context) {
 		})
 		return
 	}
-	
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Success: true,
 		Message: "Latest air quality data retrieved successfully",
-		Data:    data,
+		Data:    selectFields(data, parseFields(c)),
 		Meta: &model.MetaData{
-			Timestamp: time.Now(),
-			Version:   "1.0.0",
+			Timestamp:  time.Now(),
+			Version:    "1.0.0",
+			Pagination: pagination,
 		},
 	})
 }
@@ -58,41 +79,70 @@ func (h *AirQualityHandler) GetStationHistory(c *gin.Context) {
 		})
 		return
 	}
-	
-	// Parse query parameters for date range
-	startDateStr := c.DefaultQuery("start_date", time.Now().AddDate(0, 0, -7).Format("2006-01-02"))
-	endDateStr := c.DefaultQuery("end_date", time.Now().Format("2006-01-02"))
-	
-	startDate, err := time.Parse("2006-01-02", startDateStr)
+
+	qc, ok := parseQCFilter(c)
+	if !ok {
+		return
+	}
+
+	// Dates are whole days in the station's time zone unless tz says otherwise
+	stationLoc, err := h.stationService.GetStationLocation(uint(id))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{
+		c.JSON(http.StatusNotFound, model.APIResponse{
 			Success: false,
 			Error: &model.APIError{
-				Code:    "INVALID_DATE",
-				Message: "Invalid start date format. Use YYYY-MM-DD",
+				Code:    "NOT_FOUND",
+				Message: "Station not found",
 				Details: err.Error(),
 			},
 		})
 		return
 	}
-	
-	endDate, err := time.Parse("2006-01-02", endDateStr)
-	if err != nil {
+	loc, ok := parseLocation(c, stationLoc)
+	if !ok {
+		return
+	}
+	startDate, endDate, ok := parseDateRange(c, 7, loc)
+	if !ok {
+		return
+	}
+
+	interval := c.DefaultQuery("interval", model.IntervalRaw)
+	agg := c.DefaultQuery("agg", model.AggregateAvg)
+	switch agg {
+	case model.AggregateAvg, model.AggregateMax, model.AggregateMin, model.AggregateP95:
+	default:
 		c.JSON(http.StatusBadRequest, model.APIResponse{
 			Success: false,
 			Error: &model.APIError{
-				Code:    "INVALID_DATE",
-				Message: "Invalid end date format. Use YYYY-MM-DD",
-				Details: err.Error(),
+				Code:    "INVALID_AGGREGATION",
+				Message: "Invalid agg. Use avg, max, min or p95",
+			},
+		})
+		return
+	}
+
+	var history interface{}
+	var pagination *model.Pagination
+	switch interval {
+	case model.IntervalRaw:
+		page, ok := parsePageRequest(c, 0, maxMeasurementLimit)
+		if !ok {
+			return
+		}
+		history, pagination, err = h.service.GetHistoricalData(uint(id), startDate, endDate, qc, page)
+	case model.IntervalHour, model.IntervalDay, model.IntervalWeek, model.IntervalMonth:
+		history, err = h.service.GetBucketedHistory(uint(id), startDate, endDate, interval, agg, qc, loc)
+	default:
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_INTERVAL",
+				Message: "Invalid interval. Use raw, hour, day, week or month",
 			},
 		})
 		return
 	}
-	
-	// Add 23:59:59 to end date to include the entire day
-	endDate = endDate.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
-	
-	history, err := h.service.GetHistoricalData(uint(id), startDate, endDate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.APIResponse{
 			Success: false,
@@ -104,23 +154,29 @@ func (h *AirQualityHandler) GetStationHistory(c *gin.Context) {
 		})
 		return
 	}
-	
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Success: true,
 		Message: "Historical air quality data retrieved successfully",
-		Data:    history,
+		Data:    selectFields(history, parseFields(c)),
 		Meta: &model.MetaData{
-			Timestamp: time.Now(),
-			Version:   "1.0.0",
+			Timestamp:  time.Now(),
+			Version:    "1.0.0",
+			Pagination: pagination,
 		},
 	})
 }
 
 // InsertAirQuality handles POST /api/v1/air-quality
 func (h *AirQualityHandler) InsertAirQuality(c *gin.Context) {
-	var data model.AirQuality
-	
-	if err := c.ShouldBindJSON(&data); err != nil {
+	policy, ok := parseConflictPolicy(c)
+	if !ok {
+		return
+	}
+
+	var input model.AirQualityInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, model.APIResponse{
 			Success: false,
 			Error: &model.APIError{
@@ -131,13 +187,25 @@ func (h *AirQualityHandler) InsertAirQuality(c *gin.Context) {
 		})
 		return
 	}
-	
-	// Set timestamp if not provided
-	if data.Timestamp.IsZero() {
-		data.Timestamp = time.Now()
-	}
-	
-	if err := h.service.InsertAirQuality(&data); err != nil {
+
+	data, status, err := h.service.InsertAirQuality(&input, policy)
+	if err != nil {
+		var ingestErr *service.IngestError
+		if errors.As(err, &ingestErr) {
+			code := http.StatusUnprocessableEntity
+			if ingestErr == service.ErrDuplicateReading {
+				code = http.StatusConflict
+			}
+			c.JSON(code, model.APIResponse{
+				Success: false,
+				Error: &model.APIError{
+					Code:    ingestErr.Code,
+					Message: ingestErr.Message,
+				},
+			})
+			return
+		}
+
 		c.JSON(http.StatusInternalServerError, model.APIResponse{
 			Success: false,
 			Error: &model.APIError{
@@ -148,10 +216,24 @@ func (h *AirQualityHandler) InsertAirQuality(c *gin.Context) {
 		})
 		return
 	}
-	
-	c.JSON(http.StatusCreated, model.APIResponse{
+
+	code := http.StatusCreated
+	message := "Air quality data inserted successfully"
+	switch status {
+	case model.BatchStatusUpdated:
+		code = http.StatusOK
+		message = "Existing air quality data overwritten successfully"
+	case model.BatchStatusIgnored:
+		code = http.StatusOK
+		message = "Air quality data already exists; request ignored"
+	}
+	if data.ISPUMismatch {
+		message += "; reported ISPU differs from computed value"
+	}
+
+	c.JSON(code, model.APIResponse{
 		Success: true,
-		Message: "Air quality data inserted successfully",
+		Message: message,
 		Data:    data,
 		Meta: &model.MetaData{
 			Timestamp: time.Now(),
@@ -159,3 +241,166 @@ func (h *AirQualityHandler) InsertAirQuality(c *gin.Context) {
 		},
 	})
 }
+
+// maxBatchSize limits the number of readings accepted in one batch upload
+const maxBatchSize = 5000
+
+// maxBatchBodyBytes limits the size of a batch upload body
+const maxBatchBodyBytes = 16 << 20
+
+// InsertAirQualityBatch handles POST /api/v1/air-quality/batch
+// The body is either a JSON array of readings or NDJSON (one reading per line).
+func (h *AirQualityHandler) InsertAirQualityBatch(c *gin.Context) {
+	policy, ok := parseConflictPolicy(c)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchBodyBytes))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "BODY_TOO_LARGE",
+				Message: "Request body is too large",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	rows, err := decodeBatchRows(body, c.ContentType())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid batch payload",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	if len(rows) == 0 || len(rows) > maxBatchSize {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_BATCH_SIZE",
+				Message: fmt.Sprintf("Batch must contain between 1 and %d readings", maxBatchSize),
+			},
+		})
+		return
+	}
+
+	report := model.BatchResult{
+		Total:   len(rows),
+		Results: make([]model.BatchRowResult, len(rows)),
+	}
+
+	// Rows that fail decoding or validation are reported without reaching the service
+	inputs := make([]model.AirQualityInput, 0, len(rows))
+	positions := make([]int, 0, len(rows))
+	for i, row := range rows {
+		var input model.AirQualityInput
+		err := json.Unmarshal(row, &input)
+		if err == nil {
+			err = binding.Validator.ValidateStruct(&input)
+		}
+		if err != nil {
+			report.Results[i] = model.BatchRowResult{
+				Index:  i,
+				Status: model.BatchStatusFailed,
+				Error: &model.APIError{
+					Code:    "VALIDATION_ERROR",
+					Message: "Invalid reading",
+					Details: err.Error(),
+				},
+			}
+			continue
+		}
+		inputs = append(inputs, input)
+		positions = append(positions, i)
+	}
+
+	for j, result := range h.service.InsertAirQualityBatch(inputs, policy) {
+		result.Index = positions[j]
+		report.Results[positions[j]] = result
+	}
+
+	for _, result := range report.Results {
+		switch result.Status {
+		case model.BatchStatusCreated:
+			report.Created++
+		case model.BatchStatusUpdated:
+			report.Updated++
+		case model.BatchStatusIgnored:
+			report.Ignored++
+		default:
+			report.Failed++
+		}
+	}
+
+	status := http.StatusCreated
+	message := "Air quality batch inserted successfully"
+	if report.Failed == report.Total {
+		status = http.StatusUnprocessableEntity
+		message = "No readings in the batch could be inserted"
+	} else if report.Failed > 0 {
+		status = http.StatusMultiStatus
+		message = "Air quality batch partially inserted"
+	}
+
+	c.JSON(status, model.APIResponse{
+		Success: report.Failed == 0,
+		Message: message,
+		Data:    report,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// decodeBatchRows splits a batch body into raw JSON rows. JSON arrays are decoded
+// as a whole; anything else is treated as NDJSON, skipping blank lines.
+func decodeBatchRows(body []byte, contentType string) ([]json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(body)
+	if contentType != "application/x-ndjson" && contentType != "application/ndjson" &&
+		len(trimmed) > 0 && trimmed[0] == '[' {
+		var rows []json.RawMessage
+		if err := json.Unmarshal(trimmed, &rows); err != nil {
+			return nil, err
+		}
+		return rows, nil
+	}
+
+	rows := make([]json.RawMessage, 0)
+	for _, line := range bytes.Split(trimmed, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		rows = append(rows, json.RawMessage(line))
+	}
+	return rows, nil
+}
+
+// parseConflictPolicy reads the on_conflict query parameter, writing an error
+// response and returning false when it is not a known policy
+func parseConflictPolicy(c *gin.Context) (model.ConflictPolicy, bool) {
+	policy := model.ConflictPolicy(c.DefaultQuery("on_conflict", string(model.ConflictReject)))
+	switch policy {
+	case model.ConflictReject, model.ConflictIgnore, model.ConflictOverwrite:
+		return policy, true
+	}
+
+	c.JSON(http.StatusBadRequest, model.APIResponse{
+		Success: false,
+		Error: &model.APIError{
+			Code:    "INVALID_CONFLICT_POLICY",
+			Message: "Invalid on_conflict value. Use reject, ignore or overwrite",
+		},
+	})
+	return "", false
+}
@@ -0,0 +1,329 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+type AlertHandler struct {
+	service *service.AlertService
+}
+
+func NewAlertHandler(service *service.AlertService) *AlertHandler {
+	return &AlertHandler{service: service}
+}
+
+// GetRules handles GET /api/v1/alerts/rules
+func (h *AlertHandler) GetRules(c *gin.Context) {
+	rules, err := h.service.ListRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to fetch alert rules",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Alert rules retrieved successfully",
+		Data:    rules,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// GetRule handles GET /api/v1/alerts/rules/:id
+func (h *AlertHandler) GetRule(c *gin.Context) {
+	id, ok := parseAlertID(c, "Invalid alert rule ID")
+	if !ok {
+		return
+	}
+
+	rule, err := h.service.GetRule(id)
+	if err != nil {
+		respondRuleNotFound(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Alert rule retrieved successfully",
+		Data:    rule,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// CreateRule handles POST /api/v1/alerts/rules
+func (h *AlertHandler) CreateRule(c *gin.Context) {
+	var rule model.AlertRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+	rule.ID = 0
+
+	if err := h.service.CreateRule(&rule); err != nil {
+		respondRuleError(c, err, "CREATE_ERROR", "Failed to create alert rule")
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APIResponse{
+		Success: true,
+		Message: "Alert rule created successfully",
+		Data:    rule,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// UpdateRule handles PUT /api/v1/alerts/rules/:id
+func (h *AlertHandler) UpdateRule(c *gin.Context) {
+	id, ok := parseAlertID(c, "Invalid alert rule ID")
+	if !ok {
+		return
+	}
+
+	var rule model.AlertRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	updated, err := h.service.UpdateRule(id, &rule)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondRuleNotFound(c, err)
+		return
+	}
+	if err != nil {
+		respondRuleError(c, err, "UPDATE_ERROR", "Failed to update alert rule")
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Alert rule updated successfully",
+		Data:    updated,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// DeleteRule handles DELETE /api/v1/alerts/rules/:id. The rule's alerts and their
+// history are deleted with it; disable the rule instead to keep them.
+func (h *AlertHandler) DeleteRule(c *gin.Context) {
+	id, ok := parseAlertID(c, "Invalid alert rule ID")
+	if !ok {
+		return
+	}
+
+	err := h.service.DeleteRule(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondRuleNotFound(c, err)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "DELETE_ERROR",
+				Message: "Failed to delete alert rule",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Alert rule deleted successfully",
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// GetAlerts handles GET /api/v1/alerts
+func (h *AlertHandler) GetAlerts(c *gin.Context) {
+	filter := model.AlertFilter{State: c.Query("state")}
+
+	switch filter.State {
+	case "", model.AlertPending, model.AlertFiring, model.AlertResolved:
+	default:
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_STATE",
+				Message: "Invalid state. Use pending, firing or resolved",
+			},
+		})
+		return
+	}
+
+	for param, target := range map[string]*uint{"station_id": &filter.StationID, "rule_id": &filter.RuleID} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Success: false,
+				Error: &model.APIError{
+					Code:    "INVALID_ID",
+					Message: "Invalid " + param,
+					Details: err.Error(),
+				},
+			})
+			return
+		}
+		*target = uint(id)
+	}
+
+	limit, ok := parseLimit(c, 100, 1000)
+	if !ok {
+		return
+	}
+	filter.Limit = limit
+
+	alerts, err := h.service.ListAlerts(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to fetch alerts",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Alerts retrieved successfully",
+		Data:    alerts,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// GetAlert handles GET /api/v1/alerts/:id and includes the alert's state history
+func (h *AlertHandler) GetAlert(c *gin.Context) {
+	id, ok := parseAlertID(c, "Invalid alert ID")
+	if !ok {
+		return
+	}
+
+	alert, err := h.service.GetAlert(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "NOT_FOUND",
+				Message: "Alert not found",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Alert retrieved successfully",
+		Data:    alert,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+func parseAlertID(c *gin.Context, message string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_ID",
+				Message: message,
+				Details: err.Error(),
+			},
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondRuleNotFound(c *gin.Context, err error) {
+	c.JSON(http.StatusNotFound, model.APIResponse{
+		Success: false,
+		Error: &model.APIError{
+			Code:    "NOT_FOUND",
+			Message: "Alert rule not found",
+			Details: err.Error(),
+		},
+	})
+}
+
+// respondRuleError reports a rejected rule as a bad request and anything else as
+// a server error
+func respondRuleError(c *gin.Context, err error, code, message string) {
+	var ruleErr *service.RuleError
+	if errors.As(err, &ruleErr) {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    ruleErr.Code,
+				Message: ruleErr.Message,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, model.APIResponse{
+		Success: false,
+		Error: &model.APIError{
+			Code:    code,
+			Message: message,
+			Details: err.Error(),
+		},
+	})
+}
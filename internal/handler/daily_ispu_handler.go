@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+type DailyISPUHandler struct {
+	service *service.DailyISPUService
+}
+
+func NewDailyISPUHandler(service *service.DailyISPUService) *DailyISPUHandler {
+	return &DailyISPUHandler{service: service}
+}
+
+// GetStationDailyISPU handles GET /api/v1/air-quality/station/:id/daily-ispu
+func (h *DailyISPUHandler) GetStationDailyISPU(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_ID",
+				Message: "Invalid station ID",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	date, ok := parseReportDate(c)
+	if !ok {
+		return
+	}
+
+	daily, err := h.service.GetStationDailyISPU(uint(id), date)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "NOT_FOUND",
+				Message: "Station not found",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to compute daily ISPU",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Daily ISPU retrieved successfully",
+		Data:    daily,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// GetNationalSummary handles GET /api/v1/air-quality/daily-summary
+func (h *DailyISPUHandler) GetNationalSummary(c *gin.Context) {
+	date, ok := parseReportDate(c)
+	if !ok {
+		return
+	}
+
+	summary, err := h.service.GetNationalSummary(date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to compute daily ISPU summary",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Daily ISPU summary retrieved successfully",
+		Data:    summary,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// parseReportDate reads the date query parameter (YYYY-MM-DD), defaulting to
+// yesterday in WIB, the latest day with a complete set of hourly data. The date is
+// a calendar day; each station evaluates it in its own time zone.
+func parseReportDate(c *gin.Context) (time.Time, bool) {
+	wib, _ := model.LoadTimezone(model.TimezoneWIB)
+	dateStr := c.DefaultQuery("date", time.Now().In(wib).AddDate(0, 0, -1).Format("2006-01-02"))
+
+	date, err := time.Parse("2006-01-02", dateStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_DATE",
+				Message: "Invalid date format. Use YYYY-MM-DD",
+				Details: err.Error(),
+			},
+		})
+		return time.Time{}, false
+	}
+	return date, true
+}
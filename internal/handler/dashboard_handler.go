@@ -1,12 +1,14 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ispu-monitoring/backend/internal/model"
 	"github.com/ispu-monitoring/backend/internal/service"
+	"gorm.io/gorm"
 )
 
 type DashboardHandler struct {
@@ -19,7 +21,12 @@ func NewDashboardHandler(service *service.DashboardService) *DashboardHandler {
 
 // GetOverview handles GET /api/v1/dashboard/overview
 func (h *DashboardHandler) GetOverview(c *gin.Context) {
-	overview, err := h.service.GetOverview()
+	qc, ok := parseQCFilter(c)
+	if !ok {
+		return
+	}
+
+	overview, err := h.service.GetOverview(qc)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.APIResponse{
 			Success: false,
@@ -31,7 +38,7 @@ func (h *DashboardHandler) GetOverview(c *gin.Context) {
 		})
 		return
 	}
-	
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Success: true,
 		Message: "Dashboard overview retrieved successfully",
@@ -45,7 +52,12 @@ func (h *DashboardHandler) GetOverview(c *gin.Context) {
 
 // GetStatistics handles GET /api/v1/dashboard/statistics
 func (h *DashboardHandler) GetStatistics(c *gin.Context) {
-	overview, err := h.service.GetOverview()
+	qc, ok := parseQCFilter(c)
+	if !ok {
+		return
+	}
+
+	overview, err := h.service.GetOverview(qc)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.APIResponse{
 			Success: false,
@@ -57,12 +69,12 @@ func (h *DashboardHandler) GetStatistics(c *gin.Context) {
 		})
 		return
 	}
-	
+
 	statistics := gin.H{
-		"summary":                overview.Summary,
-		"category_distribution":  overview.CategoryDistribution,
+		"summary":               overview.Summary,
+		"category_distribution": overview.CategoryDistribution,
 	}
-	
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Success: true,
 		Message: "Statistics retrieved successfully",
@@ -74,6 +86,32 @@ func (h *DashboardHandler) GetStatistics(c *gin.Context) {
 	})
 }
 
+// GetDataAvailability handles GET /api/v1/dashboard/availability
+func (h *DashboardHandler) GetDataAvailability(c *gin.Context) {
+	availability, err := h.service.GetDataAvailability()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to fetch data availability",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Data availability retrieved successfully",
+		Data:    availability,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
 // GetCategories handles GET /api/v1/categories
 func (h *DashboardHandler) GetCategories(c *gin.Context) {
 	categories, err := h.service.GetCategories()
@@ -88,7 +126,7 @@ func (h *DashboardHandler) GetCategories(c *gin.Context) {
 		})
 		return
 	}
-	
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Success: true,
 		Message: "Categories retrieved successfully",
@@ -99,3 +137,65 @@ func (h *DashboardHandler) GetCategories(c *gin.Context) {
 		},
 	})
 }
+
+// GetProvinces handles GET /api/v1/dashboard/provinces
+func (h *DashboardHandler) GetProvinces(c *gin.Context) {
+	stats, err := h.service.GetProvinceStatistics()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to fetch province statistics",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Province statistics retrieved successfully",
+		Data:    stats,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// GetProvinceDetail handles GET /api/v1/dashboard/provinces/:province
+func (h *DashboardHandler) GetProvinceDetail(c *gin.Context) {
+	detail, err := h.service.GetProvinceDetail(c.Param("province"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "NOT_FOUND",
+				Message: "Province has no active stations",
+			},
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to fetch province statistics",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Province statistics retrieved successfully",
+		Data:    detail,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
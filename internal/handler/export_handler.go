@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/service"
+)
+
+// maxExportStations bounds the number of stations in one export
+const maxExportStations = 50
+
+// exportContentTypes maps export formats to their media types
+var exportContentTypes = map[string]string{
+	service.ExportCSV:  "text/csv; charset=utf-8",
+	service.ExportXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+type ExportHandler struct {
+	service        *service.ExportService
+	stationService *service.StationService
+}
+
+func NewExportHandler(service *service.ExportService, stationService *service.StationService) *ExportHandler {
+	return &ExportHandler{
+		service:        service,
+		stationService: stationService,
+	}
+}
+
+// Export handles GET /api/v1/air-quality/export
+func (h *ExportHandler) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", service.ExportCSV)
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_FORMAT",
+				Message: "Invalid format. Use csv or xlsx",
+			},
+		})
+		return
+	}
+
+	stations, ok := h.parseStations(c)
+	if !ok {
+		return
+	}
+
+	qc, ok := parseQCFilter(c)
+	if !ok {
+		return
+	}
+
+	// A single station exports its own local days; several stations default to WIB
+	defaultLoc := stations[0].Location()
+	if len(stations) > 1 {
+		defaultLoc, _ = model.LoadTimezone(model.TimezoneWIB)
+	}
+	loc, ok := parseLocation(c, defaultLoc)
+	if !ok {
+		return
+	}
+	startDate, endDate, ok := parseDateRange(c, 7, loc)
+	if !ok {
+		return
+	}
+
+	filename := fmt.Sprintf("ispu-export-%s-%s.%s", startDate.In(loc).Format("20060102"), endDate.In(loc).AddDate(0, 0, -1).Format("20060102"), format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so a failure can only cut the download short
+	if err := h.service.Export(c.Writer, format, stations, startDate, endDate, qc); err != nil {
+		log.Printf("Error exporting air quality data: %v", err)
+	}
+}
+
+// parseStations resolves the station_ids or station_codes query parameter, both
+// comma-separated lists
+func (h *ExportHandler) parseStations(c *gin.Context) ([]model.Station, bool) {
+	ids := splitList(c.Query("station_ids"))
+	codes := splitList(c.Query("station_codes"))
+
+	if len(ids)+len(codes) == 0 || len(ids)+len(codes) > maxExportStations {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_STATIONS",
+				Message: fmt.Sprintf("Select between 1 and %d stations with station_ids or station_codes", maxExportStations),
+			},
+		})
+		return nil, false
+	}
+
+	stations := make([]model.Station, 0, len(ids)+len(codes))
+	seen := make(map[uint]bool)
+	add := func(station *model.Station, err error, ref string) bool {
+		if err != nil {
+			c.JSON(http.StatusNotFound, model.APIResponse{
+				Success: false,
+				Error: &model.APIError{
+					Code:    "STATION_NOT_FOUND",
+					Message: "Station not found: " + ref,
+					Details: err.Error(),
+				},
+			})
+			return false
+		}
+		if !seen[station.ID] {
+			seen[station.ID] = true
+			stations = append(stations, *station)
+		}
+		return true
+	}
+
+	for _, raw := range ids {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Success: false,
+				Error: &model.APIError{
+					Code:    "INVALID_ID",
+					Message: "Invalid station ID: " + raw,
+					Details: err.Error(),
+				},
+			})
+			return nil, false
+		}
+		station, err := h.stationService.GetStationByID(uint(id))
+		if !add(station, err, raw) {
+			return nil, false
+		}
+	}
+	for _, code := range codes {
+		station, err := h.stationService.GetStationByCode(code)
+		if !add(station, err, code) {
+			return nil, false
+		}
+	}
+	return stations, true
+}
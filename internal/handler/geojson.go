@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ispu-monitoring/backend/internal/model"
+)
+
+// wantsGeoJSON reports whether the client asked for GeoJSON with format=geojson or
+// an Accept header of application/geo+json
+func wantsGeoJSON(c *gin.Context) bool {
+	if format := c.Query("format"); format != "" {
+		return strings.EqualFold(format, "geojson")
+	}
+	return strings.Contains(c.GetHeader("Accept"), model.GeoJSONMediaType)
+}
+
+// respondGeoJSON writes a bare feature collection, as GIS clients do not understand
+// the API response envelope
+func respondGeoJSON(c *gin.Context, features []model.Feature) {
+	if features == nil {
+		features = []model.Feature{}
+	}
+	c.Header("Content-Type", model.GeoJSONMediaType)
+	c.JSON(http.StatusOK, model.FeatureCollection{
+		Type:     model.GeoJSONFeatureCollection,
+		Features: features,
+	})
+}
+
+// pollutantProperties adds the concentrations of a reading to feature properties
+func pollutantProperties(properties map[string]interface{}, reading *model.StationWithAirQuality) {
+	properties["pm25"] = reading.PM25
+	properties["pm10"] = reading.PM10
+	properties["co"] = reading.CO
+	properties["no2"] = reading.NO2
+	properties["o3"] = reading.O3
+	properties["so2"] = reading.SO2
+	properties["hc"] = reading.HC
+}
+
+// mapStationFeature converts a station with its latest reading to a point feature
+func mapStationFeature(station *model.StationWithAirQuality) model.Feature {
+	properties := map[string]interface{}{
+		"id":                 station.ID,
+		"name":               station.Name,
+		"code":               station.Code,
+		"type":               station.Type,
+		"province":           station.Province,
+		"city":               station.City,
+		"address":            station.Address,
+		"ispu":               station.ISPU,
+		"category":           station.Category,
+		"color":              station.Color,
+		"critical_pollutant": station.CriticalPollutant,
+		"timestamp":          station.Timestamp,
+		"status":             station.Status,
+	}
+	pollutantProperties(properties, station)
+
+	return model.Feature{
+		Type:       model.GeoJSONFeature,
+		ID:         station.ID,
+		Geometry:   model.PointGeometry(station.Latitude, station.Longitude),
+		Properties: properties,
+	}
+}
+
+// stationFeature converts a station to a point feature, with the properties of its
+// latest reading when it has one
+func stationFeature(station *model.Station, reading *model.StationWithAirQuality) model.Feature {
+	properties := map[string]interface{}{
+		"id":        station.ID,
+		"name":      station.Name,
+		"code":      station.Code,
+		"type":      station.Type,
+		"province":  station.Province,
+		"city":      station.City,
+		"address":   station.Address,
+		"timezone":  station.Timezone,
+		"is_active": station.IsActive,
+		"status":    station.Status,
+		"ispu":      nil,
+	}
+	if reading != nil {
+		properties["ispu"] = reading.ISPU
+		properties["category"] = reading.Category
+		properties["color"] = reading.Color
+		properties["critical_pollutant"] = reading.CriticalPollutant
+		properties["timestamp"] = reading.Timestamp
+		pollutantProperties(properties, reading)
+	}
+
+	return model.Feature{
+		Type:       model.GeoJSONFeature,
+		ID:         station.ID,
+		Geometry:   model.PointGeometry(station.Latitude, station.Longitude),
+		Properties: properties,
+	}
+}
+
+// clusterFeature converts a station cluster to a point feature at its centroid
+func clusterFeature(cluster *model.MapCluster) model.Feature {
+	return model.Feature{
+		Type:     model.GeoJSONFeature,
+		Geometry: model.PointGeometry(cluster.Latitude, cluster.Longitude),
+		Properties: map[string]interface{}{
+			"cluster":        true,
+			"point_count":    cluster.Count,
+			"average_ispu":   cluster.AverageISPU,
+			"worst_ispu":     cluster.WorstISPU,
+			"worst_category": cluster.WorstCategory,
+			"color":          cluster.WorstColor,
+			"station_ids":    cluster.StationIDs,
+		},
+	}
+}
+
+// mapFeatures converts a map payload, either a station list or clusters, to features
+func mapFeatures(data interface{}) []model.Feature {
+	var features []model.Feature
+	switch payload := data.(type) {
+	case []model.StationWithAirQuality:
+		for i := range payload {
+			features = append(features, mapStationFeature(&payload[i]))
+		}
+	case *model.MapClusters:
+		for i := range payload.Clusters {
+			features = append(features, clusterFeature(&payload.Clusters[i]))
+		}
+		for i := range payload.Stations {
+			features = append(features, mapStationFeature(&payload.Stations[i]))
+		}
+	}
+	return features
+}
+
+// heatmapFeatures converts every heatmap cell to a square polygon feature
+func heatmapFeatures(grid *model.HeatmapGrid) []model.Feature {
+	half := grid.Resolution / 2
+	features := make([]model.Feature, 0, len(grid.Cells))
+	for _, cell := range grid.Cells {
+		west, east := cell.Longitude-half, cell.Longitude+half
+		south, north := cell.Latitude-half, cell.Latitude+half
+
+		features = append(features, model.Feature{
+			Type: model.GeoJSONFeature,
+			Geometry: model.Geometry{
+				Type: model.GeoJSONPolygon,
+				// Exterior ring, counterclockwise and closed
+				Coordinates: [][][]float64{{
+					{west, south}, {east, south}, {east, north}, {west, north}, {west, south},
+				}},
+			},
+			Properties: map[string]interface{}{
+				"row":      cell.Row,
+				"col":      cell.Col,
+				"ispu":     cell.ISPU,
+				"category": cell.Category,
+				"color":    cell.Color,
+			},
+		})
+	}
+	return features
+}
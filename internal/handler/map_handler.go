@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/service"
+)
+
+// Bounds of the heatmap parameters
+const (
+	defaultHeatmapResolution = 0.25
+	minHeatmapResolution     = 0.005
+	maxHeatmapResolution     = 5.0
+	defaultHeatmapRadiusKm   = 100.0
+	maxHeatmapRadiusKm       = 1000.0
+)
+
+type MapHandler struct {
+	heatmapService *service.HeatmapService
+}
+
+func NewMapHandler(heatmapService *service.HeatmapService) *MapHandler {
+	return &MapHandler{heatmapService: heatmapService}
+}
+
+// GetHeatmap handles GET /api/v1/map/heatmap
+func (h *MapHandler) GetHeatmap(c *gin.Context) {
+	bbox, ok := parseBoundingBox(c)
+	if !ok {
+		return
+	}
+	if bbox == nil {
+		bbox = &service.IndonesiaBounds
+	}
+
+	resolution, err := strconv.ParseFloat(c.DefaultQuery("resolution", strconv.FormatFloat(defaultHeatmapResolution, 'f', -1, 64)), 64)
+	if err != nil || resolution < minHeatmapResolution || resolution > maxHeatmapResolution {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_RESOLUTION",
+				Message: fmt.Sprintf("Invalid resolution. Use a cell size in degrees between %g and %g", minHeatmapResolution, maxHeatmapResolution),
+			},
+		})
+		return
+	}
+
+	radius, err := strconv.ParseFloat(c.DefaultQuery("radius_km", strconv.FormatFloat(defaultHeatmapRadiusKm, 'f', -1, 64)), 64)
+	if err != nil || radius <= 0 || radius > maxHeatmapRadiusKm {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_RADIUS",
+				Message: fmt.Sprintf("Invalid radius_km. Use a number above 0 and up to %g", maxHeatmapRadiusKm),
+			},
+		})
+		return
+	}
+
+	cells := math.Ceil((bbox.MaxLat-bbox.MinLat)/resolution) * math.Ceil((bbox.MaxLon-bbox.MinLon)/resolution)
+	if cells > service.MaxHeatmapCells {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "GRID_TOO_LARGE",
+				Message: fmt.Sprintf("The grid would have %.0f cells. Use a coarser resolution or a smaller bbox (at most %d cells)", cells, service.MaxHeatmapCells),
+			},
+		})
+		return
+	}
+
+	grid, err := h.heatmapService.GetGrid(*bbox, resolution, radius)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to build heatmap",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	if wantsGeoJSON(c) {
+		respondGeoJSON(c, heatmapFeatures(grid))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Heatmap retrieved successfully",
+		Data:    grid,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// GetTile handles GET /api/v1/map/tiles/:z/:x/:y.png
+func (h *MapHandler) GetTile(c *gin.Context) {
+	yParam := c.Param("y")
+	z, zErr := strconv.Atoi(c.Param("z"))
+	x, xErr := strconv.Atoi(c.Param("x"))
+	y, yErr := strconv.Atoi(strings.TrimSuffix(yParam, ".png"))
+	if zErr != nil || xErr != nil || yErr != nil || !strings.HasSuffix(yParam, ".png") || !service.TileExists(z, x, y) {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "TILE_NOT_FOUND",
+				Message: "Tile does not exist. Use /map/tiles/{z}/{x}/{y}.png with zoom 0-22",
+			},
+		})
+		return
+	}
+
+	tile, err := h.heatmapService.RenderTile(z, x, y)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "RENDER_ERROR",
+				Message: "Failed to render tile",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.heatmapService.CacheTTL().Seconds())))
+	c.Data(http.StatusOK, "image/png", tile)
+}
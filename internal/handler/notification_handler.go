@@ -0,0 +1,349 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+type NotificationHandler struct {
+	service *service.NotificationService
+}
+
+func NewNotificationHandler(service *service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{service: service}
+}
+
+// GetSubscribers handles GET /api/v1/notifications/subscribers
+func (h *NotificationHandler) GetSubscribers(c *gin.Context) {
+	subscribers, err := h.service.ListSubscribers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to fetch subscribers",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Subscribers retrieved successfully",
+		Data:    subscribers,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// GetSubscriber handles GET /api/v1/notifications/subscribers/:id
+func (h *NotificationHandler) GetSubscriber(c *gin.Context) {
+	id, ok := parseSubscriberID(c)
+	if !ok {
+		return
+	}
+
+	subscriber, err := h.service.GetSubscriber(id)
+	if err != nil {
+		respondSubscriberNotFound(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Subscriber retrieved successfully",
+		Data:    subscriber,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// CreateSubscriber handles POST /api/v1/notifications/subscribers
+func (h *NotificationHandler) CreateSubscriber(c *gin.Context) {
+	var subscriber model.NotificationSubscriber
+	if err := c.ShouldBindJSON(&subscriber); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+	subscriber.ID = 0
+
+	if err := h.service.CreateSubscriber(&subscriber); err != nil {
+		respondSubscriberError(c, err, "CREATE_ERROR", "Failed to create subscriber")
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APIResponse{
+		Success: true,
+		Message: "Subscriber created successfully",
+		Data:    subscriber,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// UpdateSubscriber handles PUT /api/v1/notifications/subscribers/:id
+func (h *NotificationHandler) UpdateSubscriber(c *gin.Context) {
+	id, ok := parseSubscriberID(c)
+	if !ok {
+		return
+	}
+
+	var subscriber model.NotificationSubscriber
+	if err := c.ShouldBindJSON(&subscriber); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	updated, err := h.service.UpdateSubscriber(id, &subscriber)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondSubscriberNotFound(c, err)
+		return
+	}
+	if err != nil {
+		respondSubscriberError(c, err, "UPDATE_ERROR", "Failed to update subscriber")
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Subscriber updated successfully",
+		Data:    updated,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// DeleteSubscriber handles DELETE /api/v1/notifications/subscribers/:id
+func (h *NotificationHandler) DeleteSubscriber(c *gin.Context) {
+	id, ok := parseSubscriberID(c)
+	if !ok {
+		return
+	}
+
+	err := h.service.DeleteSubscriber(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondSubscriberNotFound(c, err)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "DELETE_ERROR",
+				Message: "Failed to delete subscriber",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Subscriber deleted successfully",
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// GetLog handles GET /api/v1/notifications/log, the record of sent and failed emails
+func (h *NotificationHandler) GetLog(c *gin.Context) {
+	filter := model.NotificationLogFilter{Kind: c.Query("kind"), Status: c.Query("status")}
+	if raw := c.Query("subscriber_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Success: false,
+				Error: &model.APIError{
+					Code:    "INVALID_SUBSCRIBER_ID",
+					Message: "Invalid subscriber_id",
+					Details: err.Error(),
+				},
+			})
+			return
+		}
+		filter.SubscriberID = uint(id)
+	}
+
+	switch filter.Kind {
+	case "", model.NotificationAlert, model.NotificationDigest:
+	default:
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_KIND",
+				Message: "Invalid kind. Use alert or digest",
+			},
+		})
+		return
+	}
+	switch filter.Status {
+	case "", model.NotificationPending, model.NotificationSent, model.NotificationFailed:
+	default:
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_STATUS",
+				Message: "Invalid status. Use pending, sent or failed",
+			},
+		})
+		return
+	}
+
+	limit, ok := parseLimit(c, 100, 1000)
+	if !ok {
+		return
+	}
+	filter.Limit = limit
+
+	entries, err := h.service.ListLog(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to fetch notification log",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Notification log retrieved successfully",
+		Data:    entries,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// SendDigest handles POST /api/v1/notifications/digest?date=YYYY-MM-DD, sending
+// the digest of a day (yesterday by default) to subscribers who have not got it yet
+func (h *NotificationHandler) SendDigest(c *gin.Context) {
+	date, ok := parseReportDate(c)
+	if !ok {
+		return
+	}
+
+	sent, err := h.service.SendDigest(date)
+	if errors.Is(err, service.ErrMailerDisabled) {
+		c.JSON(http.StatusServiceUnavailable, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "EMAIL_DISABLED",
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "DIGEST_ERROR",
+				Message: "Failed to send daily digest",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Daily digest sent successfully",
+		Data: gin.H{
+			"date": date.Format("2006-01-02"),
+			"sent": sent,
+		},
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+func parseSubscriberID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_ID",
+				Message: "Invalid subscriber ID",
+				Details: err.Error(),
+			},
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondSubscriberNotFound(c *gin.Context, err error) {
+	c.JSON(http.StatusNotFound, model.APIResponse{
+		Success: false,
+		Error: &model.APIError{
+			Code:    "NOT_FOUND",
+			Message: "Subscriber not found",
+			Details: err.Error(),
+		},
+	})
+}
+
+// respondSubscriberError reports a rejected subscriber as a bad request and
+// anything else as a server error
+func respondSubscriberError(c *gin.Context, err error, code, message string) {
+	var subscriberErr *service.SubscriberError
+	if errors.As(err, &subscriberErr) {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    subscriberErr.Code,
+				Message: subscriberErr.Message,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, model.APIResponse{
+		Success: false,
+		Error: &model.APIError{
+			Code:    code,
+			Message: message,
+			Details: err.Error(),
+		},
+	})
+}
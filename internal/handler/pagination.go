@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/service"
+)
+
+// Largest page sizes of the list endpoints. Lists are only paged when the client
+// sends a limit, so clients unaware of paging still get every item.
+const (
+	maxStationLimit     = 1000
+	maxMeasurementLimit = 5000
+)
+
+// parseLimit reads the limit query parameter, capped at maxLimit. It writes an error
+// response and returns false on bad input.
+func parseLimit(c *gin.Context, defaultLimit, maxLimit int) (int, bool) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return defaultLimit, true
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > maxLimit {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_LIMIT",
+				Message: "Invalid limit. Use a number between 1 and " + strconv.Itoa(maxLimit),
+			},
+		})
+		return 0, false
+	}
+	return limit, true
+}
+
+// parseOffset reads the offset query parameter of offset-paged lists
+func parseOffset(c *gin.Context) (int, bool) {
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_OFFSET",
+				Message: "Invalid offset. Use a non-negative number",
+			},
+		})
+		return 0, false
+	}
+	return offset, true
+}
+
+// parseStationSort reads the sort parameter of station lists, e.g. sort=-province
+func parseStationSort(c *gin.Context) (string, bool) {
+	sortBy := c.DefaultQuery("sort", "name")
+	if !service.IsStationSortKey(sortBy) {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_SORT",
+				Message: "Invalid sort. Use name, code, province, city or created_at, prefixed with - for descending order",
+			},
+		})
+		return "", false
+	}
+	return sortBy, true
+}
+
+// parsePageRequest reads limit, cursor and sort (timestamp or -timestamp, newest
+// first by default) for keyset-paged measurement lists
+func parsePageRequest(c *gin.Context, defaultLimit, maxLimit int) (model.PageRequest, bool) {
+	limit, ok := parseLimit(c, defaultLimit, maxLimit)
+	if !ok {
+		return model.PageRequest{}, false
+	}
+	page := model.PageRequest{Limit: limit}
+
+	switch c.DefaultQuery("sort", "-timestamp") {
+	case "timestamp":
+		page.Ascending = true
+	case "-timestamp":
+	default:
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_SORT",
+				Message: "Invalid sort. Use timestamp or -timestamp",
+			},
+		})
+		return model.PageRequest{}, false
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := model.ParseCursor(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Success: false,
+				Error: &model.APIError{
+					Code:    "INVALID_CURSOR",
+					Message: "Invalid cursor. Use the next_cursor value of a previous page",
+					Details: err.Error(),
+				},
+			})
+			return model.PageRequest{}, false
+		}
+		page.Cursor = cursor
+	}
+	return page, true
+}
+
+// parseFields reads the fields query parameter, a comma-separated list of the JSON
+// fields to keep in each item
+func parseFields(c *gin.Context) []string {
+	return splitList(c.Query("fields"))
+}
+
+// selectFields trims every item of a list down to the requested top-level JSON
+// fields. The list is returned unchanged when no fields are requested.
+func selectFields(items interface{}, fields []string) interface{} {
+	if len(fields) == 0 {
+		return items
+	}
+
+	raw, err := json.Marshal(items)
+	if err != nil {
+		return items
+	}
+	var decoded []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return items
+	}
+
+	trimmed := make([]map[string]json.RawMessage, len(decoded))
+	for i, item := range decoded {
+		trimmed[i] = make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if value, ok := item[field]; ok {
+				trimmed[i][field] = value
+			}
+		}
+	}
+	return trimmed
+}
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+type QualityHandler struct {
+	service *service.AnomalyDetectionService
+}
+
+func NewQualityHandler(service *service.AnomalyDetectionService) *QualityHandler {
+	return &QualityHandler{service: service}
+}
+
+// GetEvents handles GET /api/v1/quality/events
+func (h *QualityHandler) GetEvents(c *gin.Context) {
+	filter := model.QualityEventFilter{
+		Pollutant: model.Pollutant(c.Query("pollutant")),
+		Type:      c.Query("type"),
+		Limit:     100,
+	}
+
+	if stationID := c.Query("station_id"); stationID != "" {
+		id, err := strconv.ParseUint(stationID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Success: false,
+				Error: &model.APIError{
+					Code:    "INVALID_ID",
+					Message: "Invalid station ID",
+					Details: err.Error(),
+				},
+			})
+			return
+		}
+		filter.StationID = uint(id)
+	}
+
+	if acknowledged := c.Query("acknowledged"); acknowledged != "" {
+		value, err := strconv.ParseBool(acknowledged)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Success: false,
+				Error: &model.APIError{
+					Code:    "INVALID_PARAMETER",
+					Message: "Invalid acknowledged value. Use true or false",
+					Details: err.Error(),
+				},
+			})
+			return
+		}
+		filter.Acknowledged = &value
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > 1000 {
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Success: false,
+				Error: &model.APIError{
+					Code:    "INVALID_PARAMETER",
+					Message: "Invalid limit. Use a number between 1 and 1000",
+				},
+			})
+			return
+		}
+		filter.Limit = value
+	}
+
+	events, err := h.service.GetEvents(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to fetch quality events",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Quality events retrieved successfully",
+		Data:    events,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// AcknowledgeEvent handles POST /api/v1/quality/events/:id/acknowledge
+func (h *QualityHandler) AcknowledgeEvent(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_ID",
+				Message: "Invalid event ID",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	event, err := h.service.AcknowledgeEvent(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "NOT_FOUND",
+				Message: "Quality event not found",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "UPDATE_ERROR",
+				Message: "Failed to acknowledge quality event",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Quality event acknowledged successfully",
+		Data:    event,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// Scan handles POST /api/v1/quality/scan
+func (h *QualityHandler) Scan(c *gin.Context) {
+	count, err := h.service.Scan()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "SCAN_ERROR",
+				Message: "Failed to scan readings for anomalies",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Anomaly scan completed successfully",
+		Data:    gin.H{"new_events": count},
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
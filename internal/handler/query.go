@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ispu-monitoring/backend/internal/model"
+)
+
+// parseQCFilter reads the qc query parameter, a comma-separated list of accepted
+// QC statuses (e.g. qc=valid or qc=valid,suspect). It writes an error response
+// and returns false when a status is unknown.
+func parseQCFilter(c *gin.Context) (model.QCFilter, bool) {
+	raw := c.Query("qc")
+	if raw == "" {
+		return nil, true
+	}
+
+	var filter model.QCFilter
+	for _, part := range strings.Split(raw, ",") {
+		flag := model.QCFlag(strings.TrimSpace(part))
+		switch flag {
+		case model.QCValid, model.QCSuspect, model.QCInvalid:
+			filter = append(filter, flag)
+		default:
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Success: false,
+				Error: &model.APIError{
+					Code:    "INVALID_QC_FILTER",
+					Message: "Invalid qc value. Use a comma-separated list of valid, suspect and invalid",
+				},
+			})
+			return nil, false
+		}
+	}
+	return filter, true
+}
+
+// parseLocation reads the tz query parameter, an IANA zone name or one of WIB, WITA
+// and WIT, falling back to the given zone. It writes an error response and returns
+// false when the zone is unknown.
+func parseLocation(c *gin.Context, fallback *time.Location) (*time.Location, bool) {
+	name := c.Query("tz")
+	if name == "" {
+		return fallback, true
+	}
+
+	loc, err := model.LoadTimezone(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_TIMEZONE",
+				Message: "Invalid tz. Use an IANA zone name such as Asia/Makassar, or WIB, WITA or WIT",
+				Details: err.Error(),
+			},
+		})
+		return nil, false
+	}
+	return loc, true
+}
+
+// validTimezone checks an optional time zone in a request body
+func validTimezone(c *gin.Context, name string) bool {
+	if name == "" {
+		return true
+	}
+	if _, err := model.LoadTimezone(name); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_TIMEZONE",
+				Message: "Invalid timezone. Use an IANA zone name such as Asia/Makassar, or WIB, WITA or WIT",
+				Details: err.Error(),
+			},
+		})
+		return false
+	}
+	return true
+}
+
+// parseDateRange reads start_date and end_date, defaulting to the last defaultDays
+// days. Each accepts an RFC 3339 timestamp or a YYYY-MM-DD date taken as a whole
+// day in loc. The returned range is half-open: a date given as end_date is included
+// up to the following local midnight. It writes an error response and returns
+// false on bad input.
+func parseDateRange(c *gin.Context, defaultDays int, loc *time.Location) (time.Time, time.Time, bool) {
+	today := model.LocalDay(time.Now().In(loc), loc)
+
+	startDate := today.AddDate(0, 0, -defaultDays)
+	if raw := c.Query("start_date"); raw != "" {
+		parsed, _, err := parseTimeParam(raw, loc)
+		if err != nil {
+			invalidDate(c, "start_date", err)
+			return time.Time{}, time.Time{}, false
+		}
+		startDate = parsed
+	}
+
+	endDate := today.AddDate(0, 0, 1)
+	if raw := c.Query("end_date"); raw != "" {
+		parsed, dateOnly, err := parseTimeParam(raw, loc)
+		if err != nil {
+			invalidDate(c, "end_date", err)
+			return time.Time{}, time.Time{}, false
+		}
+		endDate = parsed
+		if dateOnly {
+			endDate = parsed.AddDate(0, 0, 1)
+		}
+	}
+
+	if !startDate.Before(endDate) {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_DATE_RANGE",
+				Message: "start_date must be before end_date",
+			},
+		})
+		return time.Time{}, time.Time{}, false
+	}
+	return startDate, endDate, true
+}
+
+// parseTimeParam parses an RFC 3339 timestamp, or a YYYY-MM-DD date as local
+// midnight in loc, reporting which form was used
+func parseTimeParam(raw string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, false, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", raw, loc)
+	return t, true, err
+}
+
+func invalidDate(c *gin.Context, param string, err error) {
+	c.JSON(http.StatusBadRequest, model.APIResponse{
+		Success: false,
+		Error: &model.APIError{
+			Code:    "INVALID_DATE",
+			Message: "Invalid " + param + ". Use YYYY-MM-DD or an RFC 3339 timestamp",
+			Details: err.Error(),
+		},
+	})
+}
+
+// maxZoom is the deepest zoom level of web map tiles
+const maxZoom = 22
+
+// parseBoundingBox reads the bbox query parameter as min_lon,min_lat,max_lon,max_lat.
+// It returns nil when no box is given, and writes an error response and returns
+// false on bad input.
+func parseBoundingBox(c *gin.Context) (*model.BoundingBox, bool) {
+	raw := c.Query("bbox")
+	if raw == "" {
+		return nil, true
+	}
+
+	invalid := func() (*model.BoundingBox, bool) {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_BBOX",
+				Message: "Invalid bbox. Use min_lon,min_lat,max_lon,max_lat in decimal degrees",
+			},
+		})
+		return nil, false
+	}
+
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		return invalid()
+	}
+	values := make([]float64, 4)
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return invalid()
+		}
+		values[i] = value
+	}
+
+	bbox := &model.BoundingBox{MinLon: values[0], MinLat: values[1], MaxLon: values[2], MaxLat: values[3]}
+	if bbox.MinLon >= bbox.MaxLon || bbox.MinLat >= bbox.MaxLat ||
+		bbox.MinLat < -90 || bbox.MaxLat > 90 || bbox.MinLon < -180 || bbox.MaxLon > 180 {
+		return invalid()
+	}
+	return bbox, true
+}
+
+// parseZoom reads the optional zoom query parameter (0-22)
+func parseZoom(c *gin.Context) (*int, bool) {
+	raw := c.Query("zoom")
+	if raw == "" {
+		return nil, true
+	}
+
+	zoom, err := strconv.Atoi(raw)
+	if err != nil || zoom < 0 || zoom > maxZoom {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_ZOOM",
+				Message: "Invalid zoom. Use a whole number between 0 and 22",
+			},
+		})
+		return nil, false
+	}
+	return &zoom, true
+}
+
+// splitList splits a comma-separated query value, dropping empty items
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
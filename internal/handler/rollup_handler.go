@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/service"
+)
+
+type RollupHandler struct {
+	service        *service.RollupService
+	stationService *service.StationService
+}
+
+func NewRollupHandler(service *service.RollupService, stationService *service.StationService) *RollupHandler {
+	return &RollupHandler{
+		service:        service,
+		stationService: stationService,
+	}
+}
+
+// RebuildRequest is the body of a rollup rebuild request
+type RebuildRequest struct {
+	StationID uint   `json:"station_id"`
+	StartDate string `json:"start_date" binding:"required"`
+	EndDate   string `json:"end_date" binding:"required"`
+}
+
+// GetAggregates handles GET /api/v1/air-quality/station/:id/aggregates
+func (h *RollupHandler) GetAggregates(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_ID",
+				Message: "Invalid station ID",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	period := c.DefaultQuery("period", model.PeriodDay)
+	if period != model.PeriodHour && period != model.PeriodDay {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_PERIOD",
+				Message: "Invalid period. Use hour or day",
+			},
+		})
+		return
+	}
+
+	stationLoc, err := h.stationService.GetStationLocation(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "NOT_FOUND",
+				Message: "Station not found",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+	loc, ok := parseLocation(c, stationLoc)
+	if !ok {
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(c, 30, loc)
+	if !ok {
+		return
+	}
+
+	aggregates, err := h.service.GetAggregates(period, uint(id), model.Pollutant(c.Query("pollutant")), startDate, endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to fetch aggregates",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Aggregates retrieved successfully",
+		Data:    aggregates,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// Rebuild handles POST /api/v1/rollups/rebuild
+func (h *RollupHandler) Rebuild(c *gin.Context) {
+	var request RebuildRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	startDate, err := time.Parse("2006-01-02", request.StartDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_DATE",
+				Message: "Invalid start date format. Use YYYY-MM-DD",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	endDate, err := time.Parse("2006-01-02", request.EndDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_DATE",
+				Message: "Invalid end date format. Use YYYY-MM-DD",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	if err := h.service.Rebuild(request.StationID, startDate, endDate.AddDate(0, 0, 1)); err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "REBUILD_ERROR",
+				Message: "Failed to rebuild rollups",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Rollups rebuilt successfully",
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -8,6 +10,13 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/ispu-monitoring/backend/internal/model"
 	"github.com/ispu-monitoring/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+// Bounds of the nearby station search
+const (
+	maxNearbyRadiusKm = 1000
+	maxNearbyLimit    = 100
 )
 
 type StationHandler struct {
@@ -25,16 +34,34 @@ func NewStationHandler(service *service.StationService, dashboardService *servic
 // GetAllStations handles GET /api/v1/stations
 func (h *StationHandler) GetAllStations(c *gin.Context) {
 	province := c.Query("province")
-	
-	var stations []model.Station
-	var err error
-	
-	if province != "" {
-		stations, err = h.service.GetStationsByProvince(province)
-	} else {
-		stations, err = h.service.GetAllStations()
+	status := c.Query("status")
+	switch status {
+	case "", model.StationStatusOnline, model.StationStatusDelayed, model.StationStatusOffline, model.StationStatusUnknown:
+	default:
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_STATUS",
+				Message: "Invalid status. Use online, delayed, offline or unknown",
+			},
+		})
+		return
 	}
-	
+
+	limit, ok := parseLimit(c, 0, maxStationLimit)
+	if !ok {
+		return
+	}
+	offset, ok := parseOffset(c)
+	if !ok {
+		return
+	}
+	sortBy, ok := parseStationSort(c)
+	if !ok {
+		return
+	}
+
+	stations, pagination, err := h.service.ListStations(province, status, sortBy, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.APIResponse{
 			Success: false,
@@ -46,10 +73,72 @@ func (h *StationHandler) GetAllStations(c *gin.Context) {
 		})
 		return
 	}
-	
+
+	if wantsGeoJSON(c) {
+		h.respondStationsGeoJSON(c, stations)
+		return
+	}
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Success: true,
 		Message: "Stations retrieved successfully",
+		Data:    selectFields(stations, parseFields(c)),
+		Meta: &model.MetaData{
+			Timestamp:  time.Now(),
+			Version:    "1.0.0",
+			Pagination: pagination,
+		},
+	})
+}
+
+// GetNearbyStations handles GET /api/v1/stations/nearby
+func (h *StationHandler) GetNearbyStations(c *gin.Context) {
+	lat, latErr := strconv.ParseFloat(c.Query("lat"), 64)
+	lon, lonErr := strconv.ParseFloat(c.Query("lon"), 64)
+	if latErr != nil || lonErr != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_COORDINATES",
+				Message: "lat and lon are required. Use decimal degrees within -90..90 and -180..180",
+			},
+		})
+		return
+	}
+
+	radius, err := strconv.ParseFloat(c.DefaultQuery("radius_km", "50"), 64)
+	if err != nil || radius <= 0 || radius > maxNearbyRadiusKm {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_RADIUS",
+				Message: fmt.Sprintf("Invalid radius_km. Use a number above 0 and up to %d", maxNearbyRadiusKm),
+			},
+		})
+		return
+	}
+
+	limit, ok := parseLimit(c, 10, maxNearbyLimit)
+	if !ok {
+		return
+	}
+
+	stations, err := h.dashboardService.GetNearbyStations(lat, lon, radius, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to search nearby stations",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Nearby stations retrieved successfully",
 		Data:    stations,
 		Meta: &model.MetaData{
 			Timestamp: time.Now(),
@@ -72,7 +161,7 @@ func (h *StationHandler) GetStationByID(c *gin.Context) {
 		})
 		return
 	}
-	
+
 	station, err := h.service.GetStationByID(uint(id))
 	if err != nil {
 		c.JSON(http.StatusNotFound, model.APIResponse{
@@ -85,7 +174,7 @@ func (h *StationHandler) GetStationByID(c *gin.Context) {
 		})
 		return
 	}
-	
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Success: true,
 		Message: "Station retrieved successfully",
@@ -111,7 +200,7 @@ func (h *StationHandler) GetStationLatestData(c *gin.Context) {
 		})
 		return
 	}
-	
+
 	station, err := h.service.GetStationByID(uint(id))
 	if err != nil {
 		c.JSON(http.StatusNotFound, model.APIResponse{
@@ -124,7 +213,7 @@ func (h *StationHandler) GetStationLatestData(c *gin.Context) {
 		})
 		return
 	}
-	
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Success: true,
 		Message: "Station data retrieved successfully",
@@ -136,10 +225,65 @@ func (h *StationHandler) GetStationLatestData(c *gin.Context) {
 	})
 }
 
+// GetStationStatusHistory handles GET /api/v1/stations/:id/status-history
+func (h *StationHandler) GetStationStatusHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_ID",
+				Message: "Invalid station ID",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	limit, ok := parseLimit(c, 100, 1000)
+	if !ok {
+		return
+	}
+
+	history, err := h.service.GetStatusHistory(uint(id), limit)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "NOT_FOUND",
+				Message: "Station not found",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to fetch station status history",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Station status history retrieved successfully",
+		Data:    history,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
 // CreateStation handles POST /api/v1/stations
 func (h *StationHandler) CreateStation(c *gin.Context) {
 	var station model.Station
-	
+
 	if err := c.ShouldBindJSON(&station); err != nil {
 		c.JSON(http.StatusBadRequest, model.APIResponse{
 			Success: false,
@@ -151,7 +295,11 @@ func (h *StationHandler) CreateStation(c *gin.Context) {
 		})
 		return
 	}
-	
+
+	if !validTimezone(c, station.Timezone) {
+		return
+	}
+
 	if err := h.service.CreateStation(&station); err != nil {
 		c.JSON(http.StatusInternalServerError, model.APIResponse{
 			Success: false,
@@ -163,7 +311,7 @@ func (h *StationHandler) CreateStation(c *gin.Context) {
 		})
 		return
 	}
-	
+
 	c.JSON(http.StatusCreated, model.APIResponse{
 		Success: true,
 		Message: "Station created successfully",
@@ -189,7 +337,7 @@ func (h *StationHandler) UpdateStation(c *gin.Context) {
 		})
 		return
 	}
-	
+
 	var station model.Station
 	if err := c.ShouldBindJSON(&station); err != nil {
 		c.JSON(http.StatusBadRequest, model.APIResponse{
@@ -202,7 +350,11 @@ func (h *StationHandler) UpdateStation(c *gin.Context) {
 		})
 		return
 	}
-	
+
+	if !validTimezone(c, station.Timezone) {
+		return
+	}
+
 	if err := h.service.UpdateStation(uint(id), &station); err != nil {
 		c.JSON(http.StatusInternalServerError, model.APIResponse{
 			Success: false,
@@ -214,7 +366,7 @@ func (h *StationHandler) UpdateStation(c *gin.Context) {
 		})
 		return
 	}
-	
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Success: true,
 		Message: "Station updated successfully",
@@ -240,7 +392,7 @@ func (h *StationHandler) DeleteStation(c *gin.Context) {
 		})
 		return
 	}
-	
+
 	if err := h.service.DeleteStation(uint(id)); err != nil {
 		c.JSON(http.StatusInternalServerError, model.APIResponse{
 			Success: false,
@@ -252,7 +404,7 @@ func (h *StationHandler) DeleteStation(c *gin.Context) {
 		})
 		return
 	}
-	
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Success: true,
 		Message: "Station deleted successfully",
@@ -265,8 +417,29 @@ func (h *StationHandler) DeleteStation(c *gin.Context) {
 
 // GetMapStations handles GET /api/v1/map/stations
 func (h *StationHandler) GetMapStations(c *gin.Context) {
-	// Use dashboard service to get map stations with ISPU data
-	mapStations, err := h.dashboardService.GetMapStationsData()
+	qc, ok := parseQCFilter(c)
+	if !ok {
+		return
+	}
+
+	bbox, ok := parseBoundingBox(c)
+	if !ok {
+		return
+	}
+	zoom, ok := parseZoom(c)
+	if !ok {
+		return
+	}
+
+	// Use dashboard service to get map stations with ISPU data, clustered when
+	// zoomed out far enough
+	var mapStations interface{}
+	var err error
+	if zoom != nil && *zoom < service.ClusterMaxZoom {
+		mapStations, err = h.dashboardService.GetMapClusters(qc, bbox, *zoom)
+	} else {
+		mapStations, err = h.dashboardService.GetMapStationsInBounds(qc, bbox)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.APIResponse{
 			Success: false,
@@ -278,7 +451,12 @@ func (h *StationHandler) GetMapStations(c *gin.Context) {
 		})
 		return
 	}
-	
+
+	if wantsGeoJSON(c) {
+		respondGeoJSON(c, mapFeatures(mapStations))
+		return
+	}
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Success: true,
 		Message: "Map stations retrieved successfully",
@@ -289,3 +467,25 @@ func (h *StationHandler) GetMapStations(c *gin.Context) {
 		},
 	})
 }
+
+// respondStationsGeoJSON writes stations as GeoJSON with their latest readings
+func (h *StationHandler) respondStationsGeoJSON(c *gin.Context, stations []model.Station) {
+	latest, err := h.dashboardService.GetLatestByStation()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to fetch latest station readings",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	features := make([]model.Feature, 0, len(stations))
+	for i := range stations {
+		features = append(features, stationFeature(&stations[i], latest[stations[i].ID]))
+	}
+	respondGeoJSON(c, features)
+}
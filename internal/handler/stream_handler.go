@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/service"
+)
+
+// streamHeartbeat keeps idle connections open through proxies
+const streamHeartbeat = 25 * time.Second
+
+type StreamHandler struct {
+	broker *service.EventBroker
+}
+
+func NewStreamHandler(broker *service.EventBroker) *StreamHandler {
+	return &StreamHandler{broker: broker}
+}
+
+// Stream handles GET /api/v1/stream, a Server-Sent Events feed of live events:
+// readings, category changes, alerts and station status changes. station_ids, station_codes, province and types narrow the
+// feed; each takes a comma-separated list.
+func (h *StreamHandler) Stream(c *gin.Context) {
+	filter, ok := parseEventFilter(c)
+	if !ok {
+		return
+	}
+
+	sub := h.broker.Subscribe(filter)
+	defer h.broker.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Stop nginx from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			c.SSEvent(event.Type, event)
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// parseEventFilter reads the live event filter from the query string
+func parseEventFilter(c *gin.Context) (service.EventFilter, bool) {
+	filter := service.EventFilter{
+		StationIDs:   make(map[uint]bool),
+		StationCodes: make(map[string]bool),
+		Provinces:    make(map[string]bool),
+		Types:        make(map[string]bool),
+	}
+
+	for _, raw := range splitList(c.Query("station_ids")) {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Success: false,
+				Error: &model.APIError{
+					Code:    "INVALID_ID",
+					Message: "Invalid station ID: " + raw,
+					Details: err.Error(),
+				},
+			})
+			return filter, false
+		}
+		filter.StationIDs[uint(id)] = true
+	}
+	for _, code := range splitList(c.Query("station_codes")) {
+		filter.StationCodes[code] = true
+	}
+	for _, province := range splitList(c.Query("province")) {
+		filter.Provinces[province] = true
+	}
+	for _, eventType := range splitList(c.Query("types")) {
+		if !model.IsLiveEventType(eventType) {
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Success: false,
+				Error: &model.APIError{
+					Code:    "INVALID_EVENT_TYPE",
+					Message: "Invalid types. Use " + strings.Join(model.LiveEventTypes, ", "),
+				},
+			})
+			return filter, false
+		}
+		filter.Types[eventType] = true
+	}
+
+	// Without a station selection the feed covers the whole network
+	filter.AllStations = len(filter.StationIDs) == 0 && len(filter.StationCodes) == 0 && len(filter.Provinces) == 0
+	return filter, true
+}
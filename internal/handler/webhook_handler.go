@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+type WebhookHandler struct {
+	service *service.WebhookService
+}
+
+func NewWebhookHandler(service *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+// GetWebhooks handles GET /api/v1/webhooks
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	webhooks, err := h.service.ListWebhooks()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to fetch webhooks",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Webhooks retrieved successfully",
+		Data:    webhooks,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// GetWebhook handles GET /api/v1/webhooks/:id
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.service.GetWebhook(id)
+	if err != nil {
+		respondWebhookNotFound(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Webhook retrieved successfully",
+		Data:    webhook,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// CreateWebhook handles POST /api/v1/webhooks. The response is the only one that
+// includes the signing secret.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var webhook model.Webhook
+	if err := c.ShouldBindJSON(&webhook); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+	webhook.ID = 0
+
+	if err := h.service.CreateWebhook(&webhook); err != nil {
+		respondWebhookError(c, err, "CREATE_ERROR", "Failed to create webhook")
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APIResponse{
+		Success: true,
+		Message: "Webhook created successfully",
+		Data:    webhook,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// UpdateWebhook handles PUT /api/v1/webhooks/:id
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	var webhook model.Webhook
+	if err := c.ShouldBindJSON(&webhook); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid request data",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	updated, err := h.service.UpdateWebhook(id, &webhook)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWebhookNotFound(c, err)
+		return
+	}
+	if err != nil {
+		respondWebhookError(c, err, "UPDATE_ERROR", "Failed to update webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Webhook updated successfully",
+		Data:    updated,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// DeleteWebhook handles DELETE /api/v1/webhooks/:id
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	err := h.service.DeleteWebhook(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWebhookNotFound(c, err)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "DELETE_ERROR",
+				Message: "Failed to delete webhook",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Webhook deleted successfully",
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+// GetDeliveries handles GET /api/v1/webhooks/:id/deliveries, the delivery log used
+// to debug failing receivers
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	filter := model.WebhookDeliveryFilter{WebhookID: id, Status: c.Query("status")}
+	switch filter.Status {
+	case "", model.DeliveryPending, model.DeliveryDelivered, model.DeliveryFailed:
+	default:
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_STATUS",
+				Message: "Invalid status. Use pending, delivered or failed",
+			},
+		})
+		return
+	}
+
+	limit, ok := parseLimit(c, 100, 1000)
+	if !ok {
+		return
+	}
+	filter.Limit = limit
+
+	deliveries, err := h.service.ListDeliveries(filter)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWebhookNotFound(c, err)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to fetch webhook deliveries",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Webhook deliveries retrieved successfully",
+		Data:    deliveries,
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
+
+func parseWebhookID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    "INVALID_ID",
+				Message: "Invalid webhook ID",
+				Details: err.Error(),
+			},
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondWebhookNotFound(c *gin.Context, err error) {
+	c.JSON(http.StatusNotFound, model.APIResponse{
+		Success: false,
+		Error: &model.APIError{
+			Code:    "NOT_FOUND",
+			Message: "Webhook not found",
+			Details: err.Error(),
+		},
+	})
+}
+
+// respondWebhookError reports a rejected webhook as a bad request and anything
+// else as a server error
+func respondWebhookError(c *gin.Context, err error, code, message string) {
+	var webhookErr *service.WebhookError
+	if errors.As(err, &webhookErr) {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Error: &model.APIError{
+				Code:    webhookErr.Code,
+				Message: webhookErr.Message,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, model.APIResponse{
+		Success: false,
+		Error: &model.APIError{
+			Code:    code,
+			Message: message,
+			Details: err.Error(),
+		},
+	})
+}
@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/service"
+)
+
+// WebSocket connection settings
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+	// wsMaxMessage bounds client messages, which only carry station codes
+	wsMaxMessage = 16 * 1024
+	// wsMaxStations bounds the subscriptions of one connection
+	wsMaxStations = 500
+)
+
+// WebSocket protocol actions sent by clients
+const (
+	wsActionSubscribe   = "subscribe"
+	wsActionUnsubscribe = "unsubscribe"
+	wsActionPing        = "ping"
+)
+
+// WebSocket protocol message types sent by the server, besides live events which
+// keep their own type (see model.LiveEventTypes)
+const (
+	wsTypeSnapshot     = "snapshot"
+	wsTypeSubscribed   = "subscribed"
+	wsTypeUnsubscribed = "unsubscribed"
+	wsTypePong         = "pong"
+	wsTypeError        = "error"
+)
+
+// wsClientMessage is a request from a client, e.g.
+// {"action":"subscribe","stations":["DKI1","DKI2"]}
+type wsClientMessage struct {
+	Action   string   `json:"action"`
+	Stations []string `json:"stations"`
+}
+
+// wsServerMessage is a protocol reply to a client
+type wsServerMessage struct {
+	Type     string          `json:"type"`
+	Stations []string        `json:"stations,omitempty"`
+	Unknown  []string        `json:"unknown,omitempty"`
+	Data     interface{}     `json:"data,omitempty"`
+	Error    *model.APIError `json:"error,omitempty"`
+}
+
+type WebSocketHandler struct {
+	broker           *service.EventBroker
+	dashboardService *service.DashboardService
+	upgrader         websocket.Upgrader
+}
+
+func NewWebSocketHandler(broker *service.EventBroker, dashboardService *service.DashboardService, allowedOrigins []string) *WebSocketHandler {
+	return &WebSocketHandler{
+		broker:           broker,
+		dashboardService: dashboardService,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 4096,
+			CheckOrigin:     originChecker(allowedOrigins),
+		},
+	}
+}
+
+// originChecker accepts requests without an Origin header (kiosks, scripts) and
+// browser requests from the CORS allow list
+func originChecker(allowedOrigins []string) func(r *http.Request) bool {
+	allowed := make(map[string]bool)
+	for _, origin := range allowedOrigins {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			allowed[origin] = true
+		}
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return origin == "" || allowed["*"] || allowed[origin]
+	}
+}
+
+// wsConnection serializes writes to one client, as a WebSocket allows only one
+// concurrent writer
+type wsConnection struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (w *wsConnection) send(message interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return w.conn.WriteJSON(message)
+}
+
+func (w *wsConnection) ping() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+}
+
+// Connect handles GET /api/v1/ws. Clients subscribe to station codes and receive a
+// snapshot of those stations followed by their live events. After a reconnect,
+// subscribing again resyncs the client with a fresh snapshot.
+func (h *WebSocketHandler) Connect(c *gin.Context) {
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written an HTTP error
+		return
+	}
+	defer conn.Close()
+
+	client := &wsConnection{conn: conn}
+	// Nothing is delivered until the client subscribes to stations
+	sub := h.broker.Subscribe(service.EventFilter{StationCodes: map[string]bool{}})
+	defer h.broker.Unsubscribe(sub)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.readLoop(client, sub)
+	}()
+
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if err := client.send(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := client.ping(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readLoop processes client requests until the connection fails or closes
+func (h *WebSocketHandler) readLoop(client *wsConnection, sub *service.Subscription) {
+	conn := client.conn
+	conn.SetReadLimit(wsMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var message wsClientMessage
+		if err := conn.ReadJSON(&message); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("WebSocket read error: %v", err)
+			}
+			return
+		}
+
+		var reply interface{}
+		switch message.Action {
+		case wsActionSubscribe:
+			reply = h.subscribe(client, sub, message.Stations)
+		case wsActionUnsubscribe:
+			reply = unsubscribe(sub, message.Stations)
+		case wsActionPing:
+			reply = wsServerMessage{Type: wsTypePong}
+		default:
+			reply = wsServerMessage{
+				Type: wsTypeError,
+				Error: &model.APIError{
+					Code:    "UNKNOWN_ACTION",
+					Message: "Unknown action. Use subscribe, unsubscribe or ping",
+				},
+			}
+		}
+		if reply == nil {
+			continue
+		}
+		if err := client.send(reply); err != nil {
+			return
+		}
+	}
+}
+
+// subscribe adds stations to the subscription and sends their snapshot. The filter
+// is widened before the snapshot is taken, so no reading falls in between; codes
+// of no active station are dropped again afterwards. Stations that have not
+// reported yet are subscribed without a snapshot entry.
+func (h *WebSocketHandler) subscribe(client *wsConnection, sub *service.Subscription, codes []string) interface{} {
+	current := sub.Filter().StationCodes
+	if len(current)+len(codes) > wsMaxStations {
+		return wsServerMessage{
+			Type: wsTypeError,
+			Error: &model.APIError{
+				Code:    "TOO_MANY_STATIONS",
+				Message: fmt.Sprintf("A connection can subscribe to at most %d stations", wsMaxStations),
+			},
+		}
+	}
+
+	codes = splitList(strings.Join(codes, ","))
+	subscribed := make(map[string]bool, len(current)+len(codes))
+	for code := range current {
+		subscribed[code] = true
+	}
+	for _, code := range codes {
+		subscribed[code] = true
+	}
+	sub.SetFilter(service.EventFilter{StationCodes: subscribed})
+
+	stations, unknown, err := h.dashboardService.GetStationSnapshot(codes)
+	if err != nil {
+		sub.SetFilter(service.EventFilter{StationCodes: current})
+		return wsServerMessage{
+			Type: wsTypeError,
+			Error: &model.APIError{
+				Code:    "FETCH_ERROR",
+				Message: "Failed to fetch station snapshot",
+				Details: err.Error(),
+			},
+		}
+	}
+
+	// The filter in use is shared with the broker, so unknown codes are dropped from a copy
+	kept := subscribed
+	if len(unknown) > 0 {
+		kept = make(map[string]bool, len(subscribed))
+		for code := range subscribed {
+			kept[code] = true
+		}
+		for _, code := range unknown {
+			if !current[code] {
+				delete(kept, code)
+			}
+		}
+		sub.SetFilter(service.EventFilter{StationCodes: kept})
+	}
+
+	if err := client.send(wsServerMessage{Type: wsTypeSnapshot, Data: stations}); err != nil {
+		return nil
+	}
+	return wsServerMessage{Type: wsTypeSubscribed, Stations: sortedKeys(kept), Unknown: unknown}
+}
+
+// unsubscribe removes stations from the subscription
+func unsubscribe(sub *service.Subscription, codes []string) interface{} {
+	current := sub.Filter().StationCodes
+	remaining := make(map[string]bool, len(current))
+	for code := range current {
+		remaining[code] = true
+	}
+	for _, code := range codes {
+		delete(remaining, strings.TrimSpace(code))
+	}
+	sub.SetFilter(service.EventFilter{StationCodes: remaining})
+	return wsServerMessage{Type: wsTypeUnsubscribed, Stations: sortedKeys(remaining)}
+}
+
+// sortedKeys lists the station codes of a subscription in a stable order
+func sortedKeys(codes map[string]bool) []string {
+	keys := make([]string, 0, len(codes))
+	for code := range codes {
+		keys = append(keys, code)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/repository"
+)
+
+// idempotencyTTL is how long a stored response can be replayed
+const idempotencyTTL = 24 * time.Hour
+
+// idempotencyPruneInterval is how often expired keys are deleted
+const idempotencyPruneInterval = time.Hour
+
+// maxIdempotentBodyBytes limits the body buffered for hashing; it matches the
+// largest body an idempotent endpoint accepts, a batch upload
+const maxIdempotentBodyBytes = 16 << 20
+
+// responseRecorder keeps a copy of the response body written by the handler
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyStore keeps the Idempotency-Key records
+type IdempotencyStore interface {
+	Reserve(key, requestHash string, ttl time.Duration) (*model.IdempotencyKey, bool, error)
+	Complete(key string, statusCode int, body []byte) error
+	Release(key string) error
+}
+
+// Idempotency replays the stored response when a request carries an
+// Idempotency-Key header that was already used with the same payload
+func Idempotency(repo IdempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > 255 {
+			abortIdempotency(c, http.StatusBadRequest, "INVALID_IDEMPOTENCY_KEY", "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodyBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			abortIdempotency(c, http.StatusRequestEntityTooLarge, "BODY_TOO_LARGE", "Request body is too large")
+			return
+		}
+		if err != nil {
+			abortIdempotency(c, http.StatusBadRequest, "INVALID_BODY", "Failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		record, reserved, err := repo.Reserve(key, requestHash, idempotencyTTL)
+		if err != nil {
+			log.Printf("Error reserving idempotency key: %v", err)
+			abortIdempotency(c, http.StatusInternalServerError, "IDEMPOTENCY_ERROR", "Failed to check Idempotency-Key")
+			return
+		}
+
+		if !reserved {
+			switch {
+			case record.RequestHash != requestHash:
+				abortIdempotency(c, http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED", "Idempotency-Key was already used with a different request")
+			case record.StatusCode == 0:
+				abortIdempotency(c, http.StatusConflict, "IDEMPOTENCY_IN_PROGRESS", "A request with this Idempotency-Key is still being processed")
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(record.StatusCode, "application/json; charset=utf-8", record.ResponseBody)
+				c.Abort()
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+
+		// A panicking handler sends no response, so the key is freed for a retry
+		// before the panic is passed on to the recovery middleware
+		defer func() {
+			if p := recover(); p != nil {
+				if err := repo.Release(key); err != nil {
+					log.Printf("Error releasing idempotency key: %v", err)
+				}
+				panic(p)
+			}
+		}()
+
+		c.Next()
+
+		// Server errors are not stored so the client can retry with the same key
+		if status := recorder.Status(); status >= http.StatusInternalServerError {
+			err = repo.Release(key)
+		} else {
+			err = repo.Complete(key, status, recorder.body.Bytes())
+		}
+		if err != nil {
+			log.Printf("Error storing idempotency key: %v", err)
+		}
+	}
+}
+
+// PruneIdempotencyKeys deletes the keys that can no longer be replayed once an
+// hour until ctx is cancelled
+func PruneIdempotencyKeys(ctx context.Context, repo *repository.IdempotencyRepository) {
+	ticker := time.NewTicker(idempotencyPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		if count, err := repo.DeleteExpired(time.Now().Add(-idempotencyTTL)); err != nil {
+			log.Printf("Error pruning idempotency keys: %v", err)
+		} else if count > 0 {
+			log.Printf("Pruned %d expired idempotency keys", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func abortIdempotency(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, model.APIResponse{
+		Success: false,
+		Error: &model.APIError{
+			Code:    code,
+			Message: message,
+		},
+		Meta: &model.MetaData{
+			Timestamp: time.Now(),
+			Version:   "1.0.0",
+		},
+	})
+}
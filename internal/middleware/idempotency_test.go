@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ispu-monitoring/backend/internal/model"
+)
+
+// memoryStore keeps Idempotency-Key records in memory like IdempotencyRepository
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]*model.IdempotencyKey
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{records: make(map[string]*model.IdempotencyKey)}
+}
+
+func (s *memoryStore) Reserve(key, requestHash string, ttl time.Duration) (*model.IdempotencyKey, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.records[key]; ok {
+		copied := *existing
+		return &copied, false, nil
+	}
+	record := &model.IdempotencyKey{Key: key, RequestHash: requestHash}
+	s.records[key] = record
+	return record, true, nil
+}
+
+func (s *memoryStore) Complete(key string, statusCode int, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key].StatusCode = statusCode
+	s.records[key].ResponseBody = body
+	return nil
+}
+
+func (s *memoryStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func postWithKey(router http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	req.Header.Set("Idempotency-Key", key)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyRejectsOversizedBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/", Idempotency(nil), func(c *gin.Context) {
+		t.Fatal("handler must not run for an oversized body")
+	})
+
+	body := bytes.Repeat([]byte("x"), maxIdempotentBodyBytes+1)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Idempotency-Key", "oversized")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	calls := 0
+	router := gin.New()
+	router.POST("/", Idempotency(newMemoryStore()), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"call": calls})
+	})
+
+	first := postWithKey(router, "reading-1", `{"pm25": 12}`)
+	replay := postWithKey(router, "reading-1", `{"pm25": 12}`)
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+	if replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", replay.Code, replay.Body, first.Code, first.Body)
+	}
+	if replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replayed response lacks the Idempotent-Replayed header")
+	}
+
+	reused := postWithKey(router, "reading-1", `{"pm25": 40}`)
+	if reused.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status for a different payload = %d, want %d", reused.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestIdempotencyRejectsConcurrentRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	router := gin.New()
+	router.POST("/", Idempotency(newMemoryStore()), func(c *gin.Context) {
+		close(started)
+		<-finish
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postWithKey(router, "reading-1", `{"pm25": 12}`) }()
+	<-started
+
+	concurrent := postWithKey(router, "reading-1", `{"pm25": 12}`)
+	close(finish)
+	first := <-done
+
+	if concurrent.Code != http.StatusConflict {
+		t.Errorf("status of the concurrent request = %d, want %d", concurrent.Code, http.StatusConflict)
+	}
+	if first.Code != http.StatusCreated {
+		t.Errorf("status of the first request = %d, want %d", first.Code, http.StatusCreated)
+	}
+}
+
+func TestIdempotencyReleasesKeyAfterPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	calls := 0
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.POST("/", Idempotency(newMemoryStore()), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	failed := postWithKey(router, "reading-1", `{"pm25": 12}`)
+	retried := postWithKey(router, "reading-1", `{"pm25": 12}`)
+
+	if failed.Code != http.StatusInternalServerError {
+		t.Errorf("status after the panic = %d, want %d", failed.Code, http.StatusInternalServerError)
+	}
+	if retried.Code != http.StatusCreated || calls != 2 {
+		t.Errorf("retry = %d after %d handler calls, want %d after 2", retried.Code, calls, http.StatusCreated)
+	}
+}
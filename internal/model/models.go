@@ -1,6 +1,11 @@
 package model
 
 import (
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -10,33 +15,688 @@ type Station struct {
 	Name      string    `json:"name" gorm:"not null" binding:"required"`
 	Code      string    `json:"code" gorm:"uniqueIndex;not null" binding:"required"`
 	Type      string    `json:"type" gorm:"not null" binding:"required"` // KLHK/INTEGRASI
-	Latitude  float64   `json:"latitude" gorm:"not null" binding:"required"`
-	Longitude float64   `json:"longitude" gorm:"not null" binding:"required"`
+	Latitude  float64   `json:"latitude" gorm:"not null;index:idx_stations_location" binding:"required"`
+	Longitude float64   `json:"longitude" gorm:"not null;index:idx_stations_location" binding:"required"`
 	Province  string    `json:"province"`
 	City      string    `json:"city"`
 	Address   string    `json:"address"`
+	Timezone  string    `json:"timezone" gorm:"size:40;not null;default:Asia/Jakarta"` // IANA name, e.g. Asia/Makassar
 	IsActive  bool      `json:"is_active" gorm:"default:true"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// ReportingInterval is the expected number of minutes between readings
+	ReportingInterval int `json:"reporting_interval" gorm:"not null;default:60" binding:"omitempty,min=1,max=1440"`
+	// Status, LastSeenAt and StatusChangedAt are maintained by the station monitor
+	Status          string     `json:"status" gorm:"size:10;not null;default:unknown;index"`
+	LastSeenAt      *time.Time `json:"last_seen_at"`
+	StatusChangedAt *time.Time `json:"status_changed_at"`
 }
 
+// Station reporting statuses. Stations are unknown until the monitor first checks them.
+const (
+	StationStatusUnknown = "unknown"
+	StationStatusOnline  = "online"
+	StationStatusDelayed = "delayed"
+	StationStatusOffline = "offline"
+)
+
+// A station is delayed once its latest reading is older than StationDelayedAfter
+// reporting intervals, and offline once it is older than StationOfflineAfter
+const (
+	DefaultReportingInterval = 60
+	StationDelayedAfter      = 1.5
+	StationOfflineAfter      = 3
+)
+
+// ReportingStatus derives the status of a station from the time of its latest
+// reading; stations that never reported are offline
+func (s *Station) ReportingStatus(lastSeen *time.Time, now time.Time) string {
+	if lastSeen == nil {
+		return StationStatusOffline
+	}
+	minutes := s.ReportingInterval
+	if minutes <= 0 {
+		minutes = DefaultReportingInterval
+	}
+	interval := time.Duration(minutes) * time.Minute
+
+	age := now.Sub(*lastSeen)
+	switch {
+	case age <= time.Duration(float64(interval)*StationDelayedAfter):
+		return StationStatusOnline
+	case age <= time.Duration(float64(interval)*StationOfflineAfter):
+		return StationStatusDelayed
+	default:
+		return StationStatusOffline
+	}
+}
+
+// StationStatusTransition records a change of a station's reporting status
+type StationStatusTransition struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	StationID      uint       `json:"station_id" gorm:"not null;index:idx_station_status_transitions_station"`
+	Station        *Station   `json:"-" gorm:"foreignKey:StationID;constraint:OnDelete:CASCADE"`
+	Status         string     `json:"status" gorm:"size:10;not null"`
+	PreviousStatus string     `json:"previous_status" gorm:"size:10;not null"`
+	LastSeenAt     *time.Time `json:"last_seen_at"`
+	ChangedAt      time.Time  `json:"changed_at" gorm:"not null;index:idx_station_status_transitions_station"`
+}
+
+// LiveEventStationStatus is published when a station's reporting status changes,
+// with its StationStatusTransition as data
+const LiveEventStationStatus = "station_status"
+
 // AirQuality represents air quality measurement
 type AirQuality struct {
-	ID        uint       `json:"id" gorm:"primaryKey"`
-	StationID uint       `json:"station_id" gorm:"index;not null"`
-	Station   *Station   `json:"station,omitempty" gorm:"foreignKey:StationID"`
-	ISPU      int        `json:"ispu" gorm:"not null" binding:"required,min=0"`
-	PM25      *float64   `json:"pm25"`
-	PM10      *float64   `json:"pm10"`
-	CO        *float64   `json:"co"`
-	NO2       *float64   `json:"no2"`
-	O3        *float64   `json:"o3"`
-	SO2       *float64   `json:"so2"`
-	HC        *float64   `json:"hc"`
-	Timestamp time.Time  `json:"timestamp" gorm:"index;not null"`
-	Category  string     `json:"category" gorm:"-"`
-	Color     string     `json:"color" gorm:"-"`
-	CreatedAt time.Time  `json:"created_at"`
+	ID        uint     `json:"id" gorm:"primaryKey"`
+	StationID uint     `json:"station_id" gorm:"index;not null;uniqueIndex:idx_air_qualities_station_timestamp"`
+	Station   *Station `json:"station,omitempty" gorm:"foreignKey:StationID"`
+	// ISPU is null when quality control leaves no valid pollutant
+	ISPU      *int      `json:"ispu"`
+	PM25      *float64  `json:"pm25"`
+	PM10      *float64  `json:"pm10"`
+	CO        *float64  `json:"co"`
+	NO2       *float64  `json:"no2"`
+	O3        *float64  `json:"o3"`
+	SO2       *float64  `json:"so2"`
+	HC        *float64  `json:"hc"`
+	Timestamp time.Time `json:"timestamp" gorm:"index;not null;uniqueIndex:idx_air_qualities_station_timestamp"`
+	Category  string    `json:"category" gorm:"-"`
+	Color     string    `json:"color" gorm:"-"`
+	CreatedAt time.Time `json:"created_at"`
+
+	SubIndices        SubIndices `json:"sub_indices" gorm:"embedded;embeddedPrefix:ispu_"`
+	CriticalPollutant Pollutant  `json:"critical_pollutant"`
+	QCFlags           QCFlags    `json:"qc_flags" gorm:"embedded;embeddedPrefix:qc_"`
+	QCStatus          QCFlag     `json:"qc_status" gorm:"size:10;index;not null;default:valid"`
+
+	// ReportedISPU keeps the ISPU sent by the device when it disagrees with the computed value
+	ReportedISPU *int `json:"reported_ispu,omitempty"`
+	ISPUMismatch bool `json:"ispu_mismatch" gorm:"not null;default:false"`
+}
+
+// AirQualityInput represents an air quality measurement submitted by a device.
+// The station is identified by station_code or station_id. Concentrations are in
+// µg/m³; ISPU is optional and only used for cross-checking.
+type AirQualityInput struct {
+	StationID   uint      `json:"station_id"`
+	StationCode string    `json:"station_code"`
+	ISPU        *int      `json:"ispu" binding:"omitempty,min=0"`
+	PM25        *float64  `json:"pm25"`
+	PM10        *float64  `json:"pm10"`
+	CO          *float64  `json:"co"`
+	NO2         *float64  `json:"no2"`
+	O3          *float64  `json:"o3"`
+	SO2         *float64  `json:"so2"`
+	HC          *float64  `json:"hc"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// BatchRowResult reports the outcome of a single row in a batch upload
+type BatchRowResult struct {
+	Index  int       `json:"index"`
+	Status string    `json:"status"`
+	ID     uint      `json:"id,omitempty"`
+	ISPU   *int      `json:"ispu,omitempty"`
+	Error  *APIError `json:"error,omitempty"`
+}
+
+// BatchResult summarizes a batch upload
+type BatchResult struct {
+	Total   int              `json:"total"`
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Ignored int              `json:"ignored"`
+	Failed  int              `json:"failed"`
+	Results []BatchRowResult `json:"results"`
+}
+
+// Batch row statuses
+const (
+	BatchStatusCreated = "created"
+	BatchStatusUpdated = "updated"
+	BatchStatusIgnored = "ignored"
+	BatchStatusFailed  = "failed"
+)
+
+// ConflictPolicy decides what happens when a reading already exists for the same station and timestamp
+type ConflictPolicy string
+
+const (
+	ConflictReject    ConflictPolicy = "reject"
+	ConflictIgnore    ConflictPolicy = "ignore"
+	ConflictOverwrite ConflictPolicy = "overwrite"
+)
+
+// IdempotencyKey stores the response of an ingest request so retries can be replayed
+type IdempotencyKey struct {
+	Key          string    `json:"key" gorm:"primaryKey;size:255"`
+	RequestHash  string    `json:"request_hash" gorm:"not null"`
+	StatusCode   int       `json:"status_code"`
+	ResponseBody []byte    `json:"-"`
+	CreatedAt    time.Time `json:"created_at" gorm:"index"`
+}
+
+// QualityEvent records a data-quality anomaly detected in a station's readings
+type QualityEvent struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	StationID      uint       `json:"station_id" gorm:"not null;uniqueIndex:idx_quality_events_detection"`
+	Station        *Station   `json:"station,omitempty" gorm:"foreignKey:StationID"`
+	Pollutant      Pollutant  `json:"pollutant" gorm:"size:10;not null;uniqueIndex:idx_quality_events_detection"`
+	Type           string     `json:"type" gorm:"size:20;not null;uniqueIndex:idx_quality_events_detection"` // flatline/spike/out_of_band
+	StartTime      time.Time  `json:"start_time" gorm:"not null;uniqueIndex:idx_quality_events_detection"`
+	EndTime        time.Time  `json:"end_time" gorm:"not null"`
+	ReadingCount   int        `json:"reading_count"`
+	Value          *float64   `json:"value"`
+	Details        string     `json:"details"`
+	Acknowledged   bool       `json:"acknowledged" gorm:"not null;default:false"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Quality event types
+const (
+	QualityEventFlatline  = "flatline"
+	QualityEventSpike     = "spike"
+	QualityEventOutOfBand = "out_of_band"
+)
+
+// QualityEventFilter narrows a quality event listing
+type QualityEventFilter struct {
+	StationID    uint
+	Pollutant    Pollutant
+	Type         string
+	Acknowledged *bool
+	Limit        int
+}
+
+// AirQualityAggregate holds the statistics of one pollutant (or the ISPU) at one
+// station over a time bucket. Readings flagged invalid are left out.
+type AirQualityAggregate struct {
+	StationID    uint      `json:"station_id" gorm:"primaryKey;autoIncrement:false"`
+	Pollutant    Pollutant `json:"pollutant" gorm:"primaryKey;size:10"`
+	BucketStart  time.Time `json:"bucket_start" gorm:"primaryKey"`
+	Mean         float64   `json:"mean"`
+	Min          float64   `json:"min"`
+	Max          float64   `json:"max"`
+	Count        int       `json:"count"`
+	Completeness float64   `json:"completeness"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// HourlyAggregate is an hourly rollup row
+type HourlyAggregate struct {
+	AirQualityAggregate `gorm:"embedded"`
+}
+
+func (HourlyAggregate) TableName() string {
+	return "air_quality_hourly"
+}
+
+// DailyAggregate is a daily rollup row
+type DailyAggregate struct {
+	AirQualityAggregate `gorm:"embedded"`
+}
+
+func (DailyAggregate) TableName() string {
+	return "air_quality_daily"
+}
+
+// Rollup periods
+const (
+	PeriodHour = "hour"
+	PeriodDay  = "day"
+)
+
+// DailyISPU is the regulation-conformant daily ISPU of a station, built from
+// hourly data using each pollutant's averaging period
+type DailyISPU struct {
+	StationID         uint                  `json:"station_id"`
+	StationCode       string                `json:"station_code"`
+	StationName       string                `json:"station_name"`
+	Province          string                `json:"province"`
+	Date              string                `json:"date"`
+	ISPU              *int                  `json:"ispu"`
+	Category          string                `json:"category"`
+	Color             string                `json:"color"`
+	CriticalPollutant Pollutant             `json:"critical_pollutant"`
+	Status            string                `json:"status"`
+	Pollutants        []DailyPollutantIndex `json:"pollutants"`
+}
+
+// DailyPollutantIndex is the daily concentration and sub-index of one pollutant
+type DailyPollutantIndex struct {
+	Pollutant       Pollutant `json:"pollutant"`
+	AveragingPeriod string    `json:"averaging_period"`
+	Concentration   *float64  `json:"concentration"`
+	SubIndex        *int      `json:"sub_index"`
+	HoursAvailable  int       `json:"hours_available"`
+	Complete        bool      `json:"complete"`
+}
+
+// Daily ISPU statuses
+const (
+	DailyStatusComplete         = "complete"
+	DailyStatusPartial          = "partial"
+	DailyStatusInsufficientData = "insufficient_data"
+)
+
+// DailyISPUSummary is the national daily ISPU report
+type DailyISPUSummary struct {
+	Date                 string         `json:"date"`
+	StationCount         int            `json:"station_count"`
+	ReportingStations    int            `json:"reporting_stations"`
+	AverageISPU          float64        `json:"average_ispu"`
+	CategoryDistribution map[string]int `json:"category_distribution"`
+	Stations             []DailyISPU    `json:"stations"`
+}
+
+// AirQualityBucket is a station's history aggregated over a time bucket
+type AirQualityBucket struct {
+	BucketStart time.Time `json:"bucket_start"`
+	Count       int       `json:"count"`
+	ISPU        *float64  `json:"ispu"`
+	Category    string    `json:"category" gorm:"-"`
+	Color       string    `json:"color" gorm:"-"`
+	PM25        *float64  `json:"pm25"`
+	PM10        *float64  `json:"pm10"`
+	CO          *float64  `json:"co"`
+	NO2         *float64  `json:"no2"`
+	O3          *float64  `json:"o3"`
+	SO2         *float64  `json:"so2"`
+	HC          *float64  `json:"hc"`
+}
+
+// History intervals and aggregations
+const (
+	IntervalRaw   = "raw"
+	IntervalHour  = "hour"
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+
+	AggregateAvg = "avg"
+	AggregateMax = "max"
+	AggregateMin = "min"
+	AggregateP95 = "p95"
+)
+
+// NearbyStation is a station found by a radius search with its latest reading
+type NearbyStation struct {
+	Station
+	DistanceKm        float64    `json:"distance_km"`
+	ISPU              *int       `json:"ispu" gorm:"-"`
+	Category          string     `json:"category,omitempty" gorm:"-"`
+	Color             string     `json:"color,omitempty" gorm:"-"`
+	CriticalPollutant Pollutant  `json:"critical_pollutant,omitempty" gorm:"-"`
+	LastUpdate        *time.Time `json:"last_update,omitempty" gorm:"-"`
+}
+
+// BoundingBox is a geographic rectangle in decimal degrees
+type BoundingBox struct {
+	MinLon float64 `json:"min_lon"`
+	MinLat float64 `json:"min_lat"`
+	MaxLon float64 `json:"max_lon"`
+	MaxLat float64 `json:"max_lat"`
+}
+
+// Contains reports whether a point lies inside the box, edges included
+func (b BoundingBox) Contains(lat, lon float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && lon >= b.MinLon && lon <= b.MaxLon
+}
+
+// MapCluster groups stations shown as a single marker at low zoom levels
+type MapCluster struct {
+	Latitude      float64     `json:"latitude"`
+	Longitude     float64     `json:"longitude"`
+	Count         int         `json:"count"`
+	AverageISPU   float64     `json:"average_ispu"`
+	WorstISPU     int         `json:"worst_ispu"`
+	WorstCategory string      `json:"worst_category"`
+	WorstColor    string      `json:"worst_color"`
+	Bounds        BoundingBox `json:"bounds"`
+	StationIDs    []uint      `json:"station_ids"`
+}
+
+// MapClusters is the map payload at zoom levels where nearby stations are
+// clustered; stations alone in their cell are returned individually
+type MapClusters struct {
+	Zoom     int                     `json:"zoom"`
+	Clusters []MapCluster            `json:"clusters"`
+	Stations []StationWithAirQuality `json:"stations"`
+}
+
+// HeatmapGrid is a regular latitude/longitude grid of interpolated ISPU values.
+// Cells beyond the influence radius of every station are left out.
+type HeatmapGrid struct {
+	BBox        BoundingBox   `json:"bbox"`
+	Resolution  float64       `json:"resolution"`
+	RadiusKm    float64       `json:"radius_km"`
+	Rows        int           `json:"rows"`
+	Cols        int           `json:"cols"`
+	Cells       []HeatmapCell `json:"cells"`
+	GeneratedAt time.Time     `json:"generated_at"`
+}
+
+// HeatmapCell is one grid cell; Row 0 and Col 0 are at the box's south-west corner
+type HeatmapCell struct {
+	Row       int     `json:"row"`
+	Col       int     `json:"col"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	ISPU      int     `json:"ispu"`
+	Category  string  `json:"category"`
+	Color     string  `json:"color"`
+}
+
+// Live event types
+const (
+	LiveEventReading        = "reading"
+	LiveEventCategoryChange = "category_change"
+)
+
+// LiveEvent is pushed to streaming clients as readings arrive
+type LiveEvent struct {
+	Type        string      `json:"type"`
+	StationID   uint        `json:"station_id"`
+	StationCode string      `json:"station_code"`
+	Province    string      `json:"province"`
+	Timestamp   time.Time   `json:"timestamp"`
+	Data        interface{} `json:"data"`
+}
+
+// CategoryChange is the data of a category_change event, raised when a station's
+// latest reading falls in a different ISPU category than the one before
+type CategoryChange struct {
+	StationName       string    `json:"station_name"`
+	PreviousCategory  string    `json:"previous_category"`
+	PreviousISPU      int       `json:"previous_ispu"`
+	Category          string    `json:"category"`
+	Color             string    `json:"color"`
+	ISPU              int       `json:"ispu"`
+	CriticalPollutant Pollutant `json:"critical_pollutant"`
+	Timestamp         time.Time `json:"timestamp"`
+}
+
+// Live event types of the alert engine
+const (
+	LiveEventAlertFiring   = "alert_firing"
+	LiveEventAlertResolved = "alert_resolved"
+)
+
+// AlertRule raises an alert when a parameter of a station stays at or above a
+// threshold for a sustained duration. The scope is a single station, a province,
+// or the whole network when neither is set. The threshold is either a value of the
+// parameter (µg/m³ for pollutants) or an ISPU category, compared against the ISPU
+// or the pollutant's sub-index.
+type AlertRule struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	Name            string    `json:"name" gorm:"not null" binding:"required"`
+	StationID       *uint     `json:"station_id" gorm:"index"`
+	Province        string    `json:"province" gorm:"index"`
+	Parameter       Pollutant `json:"parameter" gorm:"size:10;not null" binding:"required"` // ispu or a pollutant
+	Threshold       *float64  `json:"threshold"`
+	Category        string    `json:"category"`
+	DurationMinutes int       `json:"duration_minutes" gorm:"not null;default:0" binding:"min=0"`
+	// Hysteresis is how far below the threshold a firing alert must fall to resolve
+	Hysteresis float64   `json:"hysteresis" gorm:"not null;default:0" binding:"min=0"`
+	Enabled    *bool     `json:"enabled" gorm:"not null;default:true"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Alert states
+const (
+	AlertPending  = "pending"
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// Alert tracks one rule breach at one station from its first breaching reading
+// until it resolves. Pending alerts that recover before the rule's duration are
+// discarded.
+type Alert struct {
+	ID            uint              `json:"id" gorm:"primaryKey"`
+	RuleID        uint              `json:"rule_id" gorm:"not null;index;uniqueIndex:idx_alerts_open,where:state <> 'resolved'"`
+	Rule          *AlertRule        `json:"rule,omitempty" gorm:"foreignKey:RuleID;constraint:OnDelete:CASCADE"`
+	StationID     uint              `json:"station_id" gorm:"not null;index;uniqueIndex:idx_alerts_open"`
+	Station       *Station          `json:"station,omitempty" gorm:"foreignKey:StationID"`
+	State         string            `json:"state" gorm:"size:10;not null;index"`
+	Threshold     float64           `json:"threshold"`
+	Value         float64           `json:"value"`
+	PeakValue     float64           `json:"peak_value"`
+	StartedAt     time.Time         `json:"started_at" gorm:"not null"`
+	FiredAt       *time.Time        `json:"fired_at"`
+	ResolvedAt    *time.Time        `json:"resolved_at"`
+	LastReadingAt time.Time         `json:"last_reading_at" gorm:"not null"`
+	History       []AlertTransition `json:"history,omitempty" gorm:"foreignKey:AlertID"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// AlertTransition records an alert entering a state
+type AlertTransition struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	AlertID   uint      `json:"alert_id" gorm:"not null;index"`
+	Alert     *Alert    `json:"-" gorm:"foreignKey:AlertID;constraint:OnDelete:CASCADE"`
+	State     string    `json:"state" gorm:"size:10;not null"`
+	Value     float64   `json:"value"`
+	Timestamp time.Time `json:"timestamp" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AlertFilter narrows an alert listing
+type AlertFilter struct {
+	RuleID    uint
+	StationID uint
+	State     string
+	Limit     int
+}
+
+// LiveEventTypes lists every live event type
+var LiveEventTypes = []string{
+	LiveEventReading,
+	LiveEventCategoryChange,
+	LiveEventAlertFiring,
+	LiveEventAlertResolved,
+	LiveEventStationStatus,
+}
+
+// IsLiveEventType reports whether eventType is one of LiveEventTypes
+func IsLiveEventType(eventType string) bool {
+	for _, known := range LiveEventTypes {
+		if eventType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// StringList is a list of strings stored as a comma-separated column
+type StringList []string
+
+// Value implements driver.Valuer
+func (l StringList) Value() (driver.Value, error) {
+	return strings.Join(l, ","), nil
+}
+
+// Scan implements sql.Scanner
+func (l *StringList) Scan(value interface{}) error {
+	var raw string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into StringList", value)
+	}
+
+	*l = StringList{}
+	if raw != "" {
+		*l = strings.Split(raw, ",")
+	}
+	return nil
+}
+
+// Webhook subscribes a partner endpoint to live events. Deliveries are signed
+// with HMAC-SHA256 using the secret, which is only returned when created.
+type Webhook struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Name       string     `json:"name" gorm:"not null" binding:"required"`
+	URL        string     `json:"url" gorm:"not null" binding:"required,url"`
+	Secret     string     `json:"secret,omitempty" gorm:"not null"`
+	EventTypes StringList `json:"event_types" gorm:"type:text;not null"`
+	Enabled    *bool      `json:"enabled" gorm:"not null;default:true"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is an outbox entry holding one event for one webhook until it is
+// delivered or runs out of attempts
+type WebhookDelivery struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	WebhookID      uint       `json:"webhook_id" gorm:"not null;index"`
+	Webhook        *Webhook   `json:"-" gorm:"foreignKey:WebhookID;constraint:OnDelete:CASCADE"`
+	EventType      string     `json:"event_type" gorm:"size:30;not null"`
+	Payload        string     `json:"payload" gorm:"type:text;not null"`
+	Status         string     `json:"status" gorm:"size:10;not null;index:idx_webhook_deliveries_due"`
+	Attempts       int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"not null;index:idx_webhook_deliveries_due"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	ResponseStatus *int       `json:"response_status"`
+	ResponseBody   string     `json:"response_body,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"index"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// WebhookDeliveryFilter narrows a delivery log listing
+type WebhookDeliveryFilter struct {
+	WebhookID uint
+	Status    string
+	Limit     int
+}
+
+// Notification languages
+const (
+	LanguageIndonesian = "id"
+	LanguageEnglish    = "en"
+)
+
+// NotificationSubscriber receives alert emails for the stations they follow and
+// a daily digest. Empty StationCodes and Provinces follow the whole network.
+type NotificationSubscriber struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	Name         string     `json:"name" gorm:"not null" binding:"required"`
+	Email        string     `json:"email" gorm:"uniqueIndex;not null" binding:"required,email"`
+	Language     string     `json:"language" gorm:"size:2;not null;default:id"` // id or en
+	StationCodes StringList `json:"station_codes" gorm:"type:text;not null"`
+	Provinces    StringList `json:"provinces" gorm:"type:text;not null"`
+	AlertEmails  *bool      `json:"alert_emails" gorm:"not null;default:true"`
+	DigestEmails *bool      `json:"digest_emails" gorm:"not null;default:true"`
+	Enabled      *bool      `json:"enabled" gorm:"not null;default:true"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Follows reports whether a station is in the subscriber's scope
+func (s *NotificationSubscriber) Follows(stationCode, province string) bool {
+	if len(s.StationCodes) == 0 && len(s.Provinces) == 0 {
+		return true
+	}
+	for _, code := range s.StationCodes {
+		if code == stationCode {
+			return true
+		}
+	}
+	for _, followed := range s.Provinces {
+		if followed == province {
+			return true
+		}
+	}
+	return false
+}
+
+// Notification kinds and statuses
+const (
+	NotificationAlert  = "alert"
+	NotificationDigest = "digest"
+
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+)
+
+// NotificationLog records an email sent to a subscriber. Reference identifies what
+// the email is about (an alert, a category change or a digest date) and is unique
+// per subscriber, so the same notification is never sent twice.
+type NotificationLog struct {
+	ID           uint                    `json:"id" gorm:"primaryKey"`
+	SubscriberID uint                    `json:"subscriber_id" gorm:"not null;uniqueIndex:idx_notification_logs_reference;index:idx_notification_logs_recent"`
+	Subscriber   *NotificationSubscriber `json:"-" gorm:"foreignKey:SubscriberID;constraint:OnDelete:CASCADE"`
+	StationID    *uint                   `json:"station_id"`
+	Kind         string                  `json:"kind" gorm:"size:10;not null"`
+	Reference    string                  `json:"reference" gorm:"size:100;not null;uniqueIndex:idx_notification_logs_reference"`
+	Subject      string                  `json:"subject"`
+	Status       string                  `json:"status" gorm:"size:10;not null"`
+	Error        string                  `json:"error,omitempty"`
+	SentAt       *time.Time              `json:"sent_at"`
+	CreatedAt    time.Time               `json:"created_at" gorm:"index:idx_notification_logs_recent"`
+}
+
+// NotificationLogFilter narrows a notification log listing
+type NotificationLogFilter struct {
+	SubscriberID uint
+	Kind         string
+	Status       string
+	Limit        int
+}
+
+// GeoJSON (RFC 7946) types
+const (
+	GeoJSONMediaType = "application/geo+json"
+
+	GeoJSONFeatureCollection = "FeatureCollection"
+	GeoJSONFeature           = "Feature"
+	GeoJSONPoint             = "Point"
+	GeoJSONPolygon           = "Polygon"
+)
+
+// FeatureCollection is a GeoJSON feature collection
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON feature
+type Feature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Geometry is a GeoJSON geometry; coordinates are in longitude, latitude order
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// PointGeometry returns a GeoJSON point
+func PointGeometry(lat, lon float64) Geometry {
+	return Geometry{Type: GeoJSONPoint, Coordinates: []float64{lon, lat}}
 }
 
 // ISPUCategory represents air quality category
@@ -52,10 +712,35 @@ type ISPUCategory struct {
 
 // DashboardOverview represents dashboard summary data
 type DashboardOverview struct {
-	Summary              DashboardSummary             `json:"summary"`
-	CategoryDistribution map[string]int               `json:"category_distribution"`
-	RecentReadings       []StationWithAirQuality      `json:"recent_readings"`
-	ProvinceStats        []ProvinceStatistic          `json:"province_stats"`
+	Summary              DashboardSummary        `json:"summary"`
+	CategoryDistribution map[string]int          `json:"category_distribution"`
+	RecentReadings       []StationWithAirQuality `json:"recent_readings"`
+	ProvinceStats        []ProvinceStatistic     `json:"province_stats"`
+	DataAvailability     DataAvailability        `json:"data_availability"`
+}
+
+// DataAvailability counts the active stations by reporting status and lists the
+// ones that are not reporting on time, longest silent first
+type DataAvailability struct {
+	TotalStations    int                   `json:"total_stations"`
+	Online           int                   `json:"online"`
+	Delayed          int                   `json:"delayed"`
+	Offline          int                   `json:"offline"`
+	Unknown          int                   `json:"unknown"`
+	OnlinePercentage float64               `json:"online_percentage"`
+	Unavailable      []StationAvailability `json:"unavailable"`
+}
+
+// StationAvailability is the reporting status of one station
+type StationAvailability struct {
+	ID                uint       `json:"id"`
+	Code              string     `json:"code"`
+	Name              string     `json:"name"`
+	Province          string     `json:"province"`
+	Status            string     `json:"status"`
+	ReportingInterval int        `json:"reporting_interval"`
+	LastSeenAt        *time.Time `json:"last_seen_at"`
+	StatusChangedAt   *time.Time `json:"status_changed_at"`
 }
 
 // DashboardSummary represents summary statistics
@@ -68,36 +753,59 @@ type DashboardSummary struct {
 
 // StationWithAirQuality combines station and latest air quality data
 type StationWithAirQuality struct {
-	ID        uint      `json:"id"`
-	Name      string    `json:"name"`
-	Code      string    `json:"code"`
-	Type      string    `json:"type"`
-	Latitude  float64   `json:"latitude"`
-	Longitude float64   `json:"longitude"`
-	Province  string    `json:"province"`
-	City      string    `json:"city"`
-	Address   string    `json:"address"`
-	ISPU      int       `json:"ispu"`
-	Category  string    `json:"category"`
-	Color     string    `json:"color"`
-	PM25      *float64  `json:"pm25"`
-	PM10      *float64  `json:"pm10"`
-	CO        *float64  `json:"co"`
-	NO2       *float64  `json:"no2"`
-	O3        *float64  `json:"o3"`
-	SO2       *float64  `json:"so2"`
-	HC        *float64  `json:"hc"`
-	Timestamp time.Time `json:"timestamp"`
-	LastUpdate time.Time `json:"last_update"`
+	ID                uint       `json:"id"`
+	Name              string     `json:"name"`
+	Code              string     `json:"code"`
+	Type              string     `json:"type"`
+	Latitude          float64    `json:"latitude"`
+	Longitude         float64    `json:"longitude"`
+	Province          string     `json:"province"`
+	City              string     `json:"city"`
+	Address           string     `json:"address"`
+	ISPU              int        `json:"ispu"`
+	Category          string     `json:"category"`
+	Color             string     `json:"color"`
+	PM25              *float64   `json:"pm25"`
+	PM10              *float64   `json:"pm10"`
+	CO                *float64   `json:"co"`
+	NO2               *float64   `json:"no2"`
+	O3                *float64   `json:"o3"`
+	SO2               *float64   `json:"so2"`
+	HC                *float64   `json:"hc"`
+	Timestamp         time.Time  `json:"timestamp"`
+	LastUpdate        time.Time  `json:"last_update"`
+	SubIndices        SubIndices `json:"sub_indices"`
+	CriticalPollutant Pollutant  `json:"critical_pollutant"`
+	Status            string     `json:"status"`
 }
 
 // ProvinceStatistic represents statistics per province
 type ProvinceStatistic struct {
-	Province       string  `json:"province"`
-	StationCount   int64   `json:"station_count"`
-	AverageISPU    float64 `json:"average_ispu"`
-	WorstCategory  string  `json:"worst_category"`
-	BestCategory   string  `json:"best_category"`
+	Province          string  `json:"province"`
+	StationCount      int64   `json:"station_count"`
+	AverageISPU       float64 `json:"average_ispu"`
+	WorstCategory     string  `json:"worst_category"`
+	BestCategory      string  `json:"best_category"`
+	ReportingStations int64   `json:"reporting_stations"`
+	WorstStation      string  `json:"worst_station,omitempty"`
+}
+
+// CityStatistic represents statistics per city within a province
+type CityStatistic struct {
+	City              string  `json:"city"`
+	StationCount      int64   `json:"station_count"`
+	ReportingStations int64   `json:"reporting_stations"`
+	AverageISPU       float64 `json:"average_ispu"`
+	WorstCategory     string  `json:"worst_category"`
+	BestCategory      string  `json:"best_category"`
+	WorstStation      string  `json:"worst_station,omitempty"`
+}
+
+// ProvinceDetail drills a province down to its cities and stations
+type ProvinceDetail struct {
+	ProvinceStatistic
+	Cities   []CityStatistic         `json:"cities"`
+	Stations []StationWithAirQuality `json:"stations"`
 }
 
 // APIResponse standard API response
@@ -118,6 +826,253 @@ type APIError struct {
 
 // MetaData represents response metadata
 type MetaData struct {
-	Timestamp time.Time `json:"timestamp"`
-	Version   string    `json:"version"`
+	Timestamp  time.Time   `json:"timestamp"`
+	Version    string      `json:"version"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// Pagination describes the page returned by a list endpoint. Measurement lists
+// page with NextCursor, station lists with Offset.
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	Offset     *int   `json:"offset,omitempty"`
+	Total      *int   `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// StationFilter selects one offset page of the active stations. Sort names a
+// station field, prefixed with "-" for descending order; a limit of 0 returns
+// every station from the offset on.
+type StationFilter struct {
+	Province string
+	Status   string
+	Sort     string
+	Limit    int
+	Offset   int
+}
+
+// PageRequest asks for one page of measurements ordered by (timestamp, id)
+type PageRequest struct {
+	Limit     int
+	Cursor    *Cursor
+	Ascending bool
+}
+
+// Cursor is a keyset position in a measurement list ordered by (timestamp, id)
+type Cursor struct {
+	Timestamp time.Time
+	ID        uint
+}
+
+// Encode returns the opaque string form of the cursor handed to clients
+func (c Cursor) Encode() string {
+	raw := fmt.Sprintf("%d:%d", c.Timestamp.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor decodes a cursor produced by Cursor.Encode
+func ParseCursor(value string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+
+	var nanos int64
+	var id uint
+	if _, err := fmt.Sscanf(string(raw), "%d:%d", &nanos, &id); err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+	return &Cursor{Timestamp: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
+
+// CursorOf returns the cursor positioned at a reading
+func CursorOf(data *AirQuality) Cursor {
+	return Cursor{Timestamp: data.Timestamp, ID: data.ID}
+}
+
+// Pollutant identifies a measured ISPU parameter
+type Pollutant string
+
+const (
+	PollutantPM25 Pollutant = "pm25"
+	PollutantPM10 Pollutant = "pm10"
+	PollutantCO   Pollutant = "co"
+	PollutantNO2  Pollutant = "no2"
+	PollutantO3   Pollutant = "o3"
+	PollutantSO2  Pollutant = "so2"
+	PollutantHC   Pollutant = "hc"
+)
+
+// ParameterISPU is used where the ISPU itself is tracked alongside pollutants
+const ParameterISPU Pollutant = "ispu"
+
+// Pollutants lists every ISPU parameter in reporting order
+var Pollutants = []Pollutant{
+	PollutantPM10,
+	PollutantPM25,
+	PollutantSO2,
+	PollutantCO,
+	PollutantO3,
+	PollutantNO2,
+	PollutantHC,
+}
+
+// SubIndices holds the ISPU sub-index computed for each reported pollutant
+type SubIndices struct {
+	PM25 *int `json:"pm25"`
+	PM10 *int `json:"pm10"`
+	CO   *int `json:"co"`
+	NO2  *int `json:"no2"`
+	O3   *int `json:"o3"`
+	SO2  *int `json:"so2"`
+	HC   *int `json:"hc"`
+}
+
+// Get returns the sub-index of a pollutant, or nil if it was not computed
+func (si *SubIndices) Get(p Pollutant) *int {
+	switch p {
+	case PollutantPM25:
+		return si.PM25
+	case PollutantPM10:
+		return si.PM10
+	case PollutantCO:
+		return si.CO
+	case PollutantNO2:
+		return si.NO2
+	case PollutantO3:
+		return si.O3
+	case PollutantSO2:
+		return si.SO2
+	case PollutantHC:
+		return si.HC
+	}
+	return nil
+}
+
+// Set stores the sub-index of a pollutant
+func (si *SubIndices) Set(p Pollutant, value *int) {
+	switch p {
+	case PollutantPM25:
+		si.PM25 = value
+	case PollutantPM10:
+		si.PM10 = value
+	case PollutantCO:
+		si.CO = value
+	case PollutantNO2:
+		si.NO2 = value
+	case PollutantO3:
+		si.O3 = value
+	case PollutantSO2:
+		si.SO2 = value
+	case PollutantHC:
+		si.HC = value
+	}
+}
+
+// QCFlag is the quality-control verdict for a measurement
+type QCFlag string
+
+const (
+	QCValid   QCFlag = "valid"
+	QCSuspect QCFlag = "suspect"
+	QCInvalid QCFlag = "invalid"
+)
+
+// Severity orders flags from valid to invalid
+func (f QCFlag) Severity() int {
+	switch f {
+	case QCSuspect:
+		return 1
+	case QCInvalid:
+		return 2
+	}
+	return 0
+}
+
+// QCFilter lists the QC statuses a caller accepts; empty accepts everything
+type QCFilter []QCFlag
+
+// QCFlags holds the quality-control flag of each reported pollutant
+type QCFlags struct {
+	PM25 QCFlag `json:"pm25,omitempty" gorm:"size:10"`
+	PM10 QCFlag `json:"pm10,omitempty" gorm:"size:10"`
+	CO   QCFlag `json:"co,omitempty" gorm:"size:10"`
+	NO2  QCFlag `json:"no2,omitempty" gorm:"size:10"`
+	O3   QCFlag `json:"o3,omitempty" gorm:"size:10"`
+	SO2  QCFlag `json:"so2,omitempty" gorm:"size:10"`
+	HC   QCFlag `json:"hc,omitempty" gorm:"size:10"`
+}
+
+// Get returns the flag of a pollutant, empty if it was not reported
+func (f *QCFlags) Get(p Pollutant) QCFlag {
+	switch p {
+	case PollutantPM25:
+		return f.PM25
+	case PollutantPM10:
+		return f.PM10
+	case PollutantCO:
+		return f.CO
+	case PollutantNO2:
+		return f.NO2
+	case PollutantO3:
+		return f.O3
+	case PollutantSO2:
+		return f.SO2
+	case PollutantHC:
+		return f.HC
+	}
+	return ""
+}
+
+// Set stores the flag of a pollutant
+func (f *QCFlags) Set(p Pollutant, flag QCFlag) {
+	switch p {
+	case PollutantPM25:
+		f.PM25 = flag
+	case PollutantPM10:
+		f.PM10 = flag
+	case PollutantCO:
+		f.CO = flag
+	case PollutantNO2:
+		f.NO2 = flag
+	case PollutantO3:
+		f.O3 = flag
+	case PollutantSO2:
+		f.SO2 = flag
+	case PollutantHC:
+		f.HC = flag
+	}
+}
+
+// Worst returns the most severe flag among the reported pollutants
+func (f *QCFlags) Worst() QCFlag {
+	worst := QCValid
+	for _, p := range Pollutants {
+		if flag := f.Get(p); flag.Severity() > worst.Severity() {
+			worst = flag
+		}
+	}
+	return worst
+}
+
+// Concentration returns the measured concentration of a pollutant, or nil if it was not reported
+func (aq *AirQuality) Concentration(p Pollutant) *float64 {
+	switch p {
+	case PollutantPM25:
+		return aq.PM25
+	case PollutantPM10:
+		return aq.PM10
+	case PollutantCO:
+		return aq.CO
+	case PollutantNO2:
+		return aq.NO2
+	case PollutantO3:
+		return aq.O3
+	case PollutantSO2:
+		return aq.SO2
+	case PollutantHC:
+		return aq.HC
+	}
+	return nil
 }
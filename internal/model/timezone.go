@@ -0,0 +1,89 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Indonesian time zones
+const (
+	TimezoneWIB  = "Asia/Jakarta"
+	TimezoneWITA = "Asia/Makassar"
+	TimezoneWIT  = "Asia/Jayapura"
+)
+
+// timezoneAliases maps the Indonesian zone abbreviations to IANA names
+var timezoneAliases = map[string]string{
+	"WIB":  TimezoneWIB,
+	"WITA": TimezoneWITA,
+	"WIT":  TimezoneWIT,
+}
+
+// provinceTimezones lists the provinces outside WIB
+var provinceTimezones = map[string]string{
+	"Bali":                TimezoneWITA,
+	"Nusa Tenggara Barat": TimezoneWITA,
+	"Nusa Tenggara Timur": TimezoneWITA,
+	"Kalimantan Selatan":  TimezoneWITA,
+	"Kalimantan Timur":    TimezoneWITA,
+	"Kalimantan Utara":    TimezoneWITA,
+	"Sulawesi Utara":      TimezoneWITA,
+	"Sulawesi Tengah":     TimezoneWITA,
+	"Sulawesi Selatan":    TimezoneWITA,
+	"Sulawesi Tenggara":   TimezoneWITA,
+	"Sulawesi Barat":      TimezoneWITA,
+	"Gorontalo":           TimezoneWITA,
+	"Maluku":              TimezoneWIT,
+	"Maluku Utara":        TimezoneWIT,
+	"Papua":               TimezoneWIT,
+	"Papua Barat":         TimezoneWIT,
+	"Papua Barat Daya":    TimezoneWIT,
+	"Papua Tengah":        TimezoneWIT,
+	"Papua Pegunungan":    TimezoneWIT,
+	"Papua Selatan":       TimezoneWIT,
+}
+
+// LoadTimezone resolves an IANA zone name or a WIB/WITA/WIT abbreviation
+func LoadTimezone(name string) (*time.Location, error) {
+	if alias, ok := timezoneAliases[strings.ToUpper(name)]; ok {
+		name = alias
+	}
+	if name == "" {
+		return nil, fmt.Errorf("empty time zone")
+	}
+	return time.LoadLocation(name)
+}
+
+// TimezoneFor guesses the time zone of a station from its province, falling back
+// to its longitude when the province is unknown
+func TimezoneFor(province string, longitude float64) string {
+	if zone, ok := provinceTimezones[province]; ok {
+		return zone
+	}
+	if province != "" {
+		return TimezoneWIB
+	}
+
+	switch {
+	case longitude >= 127:
+		return TimezoneWIT
+	case longitude >= 115:
+		return TimezoneWITA
+	}
+	return TimezoneWIB
+}
+
+// Location returns the station's time zone, WIB when it is unset or unknown
+func (s *Station) Location() *time.Location {
+	if loc, err := LoadTimezone(s.Timezone); err == nil {
+		return loc
+	}
+	loc, _ := time.LoadLocation(TimezoneWIB)
+	return loc
+}
+
+// LocalDay returns local midnight of a calendar date in loc
+func LocalDay(date time.Time, loc *time.Location) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+}
@@ -1,10 +1,14 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/ispu-monitoring/backend/internal/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AirQualityRepository struct {
@@ -15,47 +19,334 @@ func NewAirQualityRepository(db *gorm.DB) *AirQualityRepository {
 	return &AirQualityRepository{db: db}
 }
 
-func (r *AirQualityRepository) GetLatestForAllStations() ([]model.AirQuality, error) {
+// WithTx returns a repository that works within tx
+func (r *AirQualityRepository) WithTx(tx *gorm.DB) *AirQualityRepository {
+	return &AirQualityRepository{db: tx}
+}
+
+// GetLatestForAllStations returns the most recent reading of each station among
+// readings whose QC status is accepted by qc. Readings without an ISPU are skipped.
+func (r *AirQualityRepository) GetLatestForAllStations(qc model.QCFilter) ([]model.AirQuality, error) {
 	var results []model.AirQuality
-	
-	subQuery := r.db.Model(&model.AirQuality{}).
-		Select("station_id, MAX(timestamp) as max_timestamp").
-		Group("station_id")
-	
-	err := r.db.
-		Joins("INNER JOIN (?) as latest ON air_qualities.station_id = latest.station_id AND air_qualities.timestamp = latest.max_timestamp", subQuery).
+	err := r.latestReadings(qc).
 		Preload("Station").
 		Order("air_qualities.timestamp DESC").
 		Find(&results).Error
-	
+
 	return results, err
 }
 
-func (r *AirQualityRepository) GetLatestByStationID(stationID uint) (*model.AirQuality, error) {
+// GetLatestPage returns one keyset page of the latest reading of each station,
+// paged like GetHistoryByStationID, and the number of stations with a latest reading
+func (r *AirQualityRepository) GetLatestPage(qc model.QCFilter, page model.PageRequest) ([]model.AirQuality, int64, error) {
+	var total int64
+	if err := r.db.Table("(?) AS latest", r.latestTimestamps(qc)).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var results []model.AirQuality
+	err := applyPage(r.latestReadings(qc), page, "air_qualities.").
+		Preload("Station").
+		Find(&results).Error
+	return results, total, err
+}
+
+// latestTimestamps selects the time of the latest reading with an ISPU of each station
+func (r *AirQualityRepository) latestTimestamps(qc model.QCFilter) *gorm.DB {
+	return applyQCFilter(r.db.Model(&model.AirQuality{}), qc, "qc_status").
+		Where("ispu IS NOT NULL").
+		Select("station_id, MAX(timestamp) as max_timestamp").
+		Group("station_id")
+}
+
+// latestReadings selects the latest reading with an ISPU of each station
+func (r *AirQualityRepository) latestReadings(qc model.QCFilter) *gorm.DB {
+	return applyQCFilter(r.db, qc, "air_qualities.qc_status").
+		Joins("INNER JOIN (?) as latest ON air_qualities.station_id = latest.station_id AND air_qualities.timestamp = latest.max_timestamp", r.latestTimestamps(qc))
+}
+
+// GetLatestByStationID returns the most recent reading of a station that has an
+// ISPU and whose QC status is accepted by qc
+func (r *AirQualityRepository) GetLatestByStationID(stationID uint, qc model.QCFilter) (*model.AirQuality, error) {
 	var airQuality model.AirQuality
-	result := r.db.
-		Where("station_id = ?", stationID).
+	result := applyQCFilter(r.db, qc, "qc_status").
+		Where("station_id = ? AND ispu IS NOT NULL", stationID).
 		Order("timestamp DESC").
 		First(&airQuality)
 	return &airQuality, result.Error
 }
 
-func (r *AirQualityRepository) GetHistoryByStationID(stationID uint, startDate, endDate time.Time) ([]model.AirQuality, error) {
-	var history []model.AirQuality
+// GetLatestExcept returns the most recent reading with an ISPU of a station other
+// than the reading with id
+func (r *AirQualityRepository) GetLatestExcept(stationID, id uint) (*model.AirQuality, error) {
+	var airQuality model.AirQuality
 	result := r.db.
-		Where("station_id = ? AND timestamp BETWEEN ? AND ?", stationID, startDate, endDate).
+		Where("station_id = ? AND id <> ? AND ispu IS NOT NULL", stationID, id).
 		Order("timestamp DESC").
-		Find(&history)
+		First(&airQuality)
+	return &airQuality, result.Error
+}
+
+// GetHistoryByStationID returns a station's readings within [startDate, endDate) ordered by
+// (timestamp, id), starting after the page cursor. One row beyond the page limit
+// is fetched so callers can tell whether another page follows; a limit of 0
+// returns every row.
+func (r *AirQualityRepository) GetHistoryByStationID(stationID uint, startDate, endDate time.Time, qc model.QCFilter, page model.PageRequest) ([]model.AirQuality, error) {
+	query := applyQCFilter(r.db, qc, "qc_status").
+		Where("station_id = ? AND timestamp >= ? AND timestamp < ?", stationID, startDate, endDate)
+
+	var history []model.AirQuality
+	result := applyPage(query, page, "").Find(&history)
 	return history, result.Error
 }
 
+// applyPage orders a measurement query by (timestamp, id) of the given table
+// prefix, starts it after the page cursor and fetches one row beyond the limit
+func applyPage(query *gorm.DB, page model.PageRequest, prefix string) *gorm.DB {
+	key := fmt.Sprintf("(%[1]stimestamp, %[1]sid)", prefix)
+	if page.Ascending {
+		if page.Cursor != nil {
+			query = query.Where(key+" > (?, ?)", page.Cursor.Timestamp, page.Cursor.ID)
+		}
+		query = query.Order(fmt.Sprintf("%[1]stimestamp ASC, %[1]sid ASC", prefix))
+	} else {
+		if page.Cursor != nil {
+			query = query.Where(key+" < (?, ?)", page.Cursor.Timestamp, page.Cursor.ID)
+		}
+		query = query.Order(fmt.Sprintf("%[1]stimestamp DESC, %[1]sid DESC", prefix))
+	}
+	if page.Limit > 0 {
+		query = query.Limit(page.Limit + 1)
+	}
+	return query
+}
+
+// bucketAggregates maps an aggregation name to its SQL template
+var bucketAggregates = map[string]string{
+	model.AggregateAvg: "AVG(%s)",
+	model.AggregateMax: "MAX(%s)",
+	model.AggregateMin: "MIN(%s)",
+	model.AggregateP95: "percentile_cont(0.95) WITHIN GROUP (ORDER BY %s)",
+}
+
+// GetBucketedHistory aggregates a station's readings within [startDate, endDate) into
+// time buckets in the database. interval is one of hour, day, week or month, with
+// buckets aligned to local time in timezone; agg is avg, max, min or p95. Values
+// flagged invalid are left out of the aggregates, as in the rollups.
+func (r *AirQualityRepository) GetBucketedHistory(stationID uint, startDate, endDate time.Time, interval, agg, timezone string, qc model.QCFilter) ([]model.AirQualityBucket, error) {
+	template, ok := bucketAggregates[agg]
+	if !ok {
+		return nil, fmt.Errorf("unknown aggregation %q", agg)
+	}
+	switch interval {
+	case model.IntervalHour, model.IntervalDay, model.IntervalWeek, model.IntervalMonth:
+	default:
+		return nil, fmt.Errorf("unknown interval %q", interval)
+	}
+
+	columns := []string{
+		fmt.Sprintf("date_trunc('%s', timestamp AT TIME ZONE @timezone) AT TIME ZONE @timezone AS bucket_start", interval),
+		"COUNT(*) AS count",
+		fmt.Sprintf(template, validValue("ispu::float8", "qc_status")) + " AS ispu",
+	}
+	for _, pollutant := range model.Pollutants {
+		value := validValue(string(pollutant), "qc_"+string(pollutant))
+		columns = append(columns, fmt.Sprintf(template, value)+" AS "+string(pollutant))
+	}
+
+	var buckets []model.AirQualityBucket
+	result := applyQCFilter(r.db.Model(&model.AirQuality{}), qc, "qc_status").
+		Select(strings.Join(columns, ", "), map[string]interface{}{"timezone": timezone}).
+		Where("station_id = ? AND timestamp >= ? AND timestamp < ?", stationID, startDate, endDate).
+		Group("bucket_start").
+		Order("bucket_start DESC").
+		Scan(&buckets)
+	return buckets, result.Error
+}
+
+// validValue is a column's value unless its QC flag column marks it invalid
+func validValue(column, flagColumn string) string {
+	return fmt.Sprintf("CASE WHEN %s IS DISTINCT FROM 'invalid' THEN %s END", flagColumn, column)
+}
+
+// StreamHistory calls fn for every reading of the given stations within
+// [startDate, endDate), ordered by station and time. Rows are read one at a time
+// instead of loading the result set into memory.
+func (r *AirQualityRepository) StreamHistory(stationIDs []uint, startDate, endDate time.Time, qc model.QCFilter, fn func(*model.AirQuality) error) error {
+	rows, err := applyQCFilter(r.db.Model(&model.AirQuality{}), qc, "qc_status").
+		Where("station_id IN ? AND timestamp >= ? AND timestamp < ?", stationIDs, startDate, endDate).
+		Order("station_id ASC, timestamp ASC").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data model.AirQuality
+		if err := r.db.ScanRows(rows, &data); err != nil {
+			return err
+		}
+		if err := fn(&data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetPeakReadings returns each active station's highest-ISPU reading within
+// [startDate, endDate) with its station, skipping readings flagged invalid or
+// without an ISPU
+func (r *AirQualityRepository) GetPeakReadings(startDate, endDate time.Time) ([]model.AirQuality, error) {
+	var readings []model.AirQuality
+	result := r.db.
+		Preload("Station").
+		Where("id IN (?)", r.db.Model(&model.AirQuality{}).
+			Select("DISTINCT ON (station_id) id").
+			Where("timestamp >= ? AND timestamp < ?", startDate, endDate).
+			Where("qc_status <> ? AND ispu IS NOT NULL", model.QCInvalid).
+			Where("station_id IN (?)", r.db.Model(&model.Station{}).Select("id").Where("is_active = ?", true)).
+			Order("station_id, ispu DESC, timestamp DESC")).
+		Order("ispu DESC").
+		Find(&readings)
+	return readings, result.Error
+}
+
 func (r *AirQualityRepository) Create(airQuality *model.AirQuality) error {
 	return r.db.Create(airQuality).Error
 }
 
-func (r *AirQualityRepository) GetAverageISPU() (float64, error) {
+// Save stores a reading while honouring the uniqueness of (station_id, timestamp).
+// It returns the batch status describing what happened to the row; with the reject
+// policy a duplicate yields gorm.ErrDuplicatedKey. When the row is created or
+// updated, afterSave runs in the same transaction, and its error rolls the save back.
+func (r *AirQualityRepository) Save(airQuality *model.AirQuality, policy model.ConflictPolicy, afterSave func(tx *gorm.DB, airQuality *model.AirQuality) error) (string, error) {
+	var status string
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		status, err = saveReading(tx, airQuality, policy, afterSave)
+		return err
+	})
+	return status, err
+}
+
+// SaveBatch stores readings in chunks, one transaction per chunk. Each row is
+// written under its own savepoint so a bad row does not abort the rest of its chunk.
+// afterSave runs under the savepoint of every created or updated row, so its error
+// fails just that row. The returned slices hold the status and error for each reading.
+func (r *AirQualityRepository) SaveBatch(items []*model.AirQuality, policy model.ConflictPolicy, chunkSize int, afterSave func(tx *gorm.DB, airQuality *model.AirQuality) error) ([]string, []error) {
+	statuses := make([]string, len(items))
+	errs := make([]error, len(items))
+
+	for start := 0; start < len(items); start += chunkSize {
+		end := start + chunkSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			for i := start; i < end; i++ {
+				item := items[i]
+				errs[i] = tx.Transaction(func(sp *gorm.DB) error {
+					var err error
+					statuses[i], err = saveReading(sp, item, policy, afterSave)
+					return err
+				})
+			}
+			return nil
+		})
+
+		// A failed commit loses every row of the chunk
+		if err != nil {
+			for i := start; i < end; i++ {
+				if errs[i] == nil {
+					errs[i] = err
+					items[i].ID = 0
+				}
+			}
+		}
+	}
+
+	return statuses, errs
+}
+
+// saveReading writes a reading and runs afterSave, when set, once the row is
+// created or updated
+func saveReading(tx *gorm.DB, airQuality *model.AirQuality, policy model.ConflictPolicy, afterSave func(tx *gorm.DB, airQuality *model.AirQuality) error) (string, error) {
+	status, err := writeReading(tx, airQuality, policy)
+	if err != nil || status == model.BatchStatusIgnored || afterSave == nil {
+		return status, err
+	}
+	return status, afterSave(tx, airQuality)
+}
+
+// writeReading inserts a reading and resolves a clash on (station_id, timestamp)
+// with INSERT ... ON CONFLICT, so concurrent writers of the same reading follow
+// the policy instead of failing on the unique index. Whether the row was created
+// comes from the rows affected by the insert.
+func writeReading(tx *gorm.DB, airQuality *model.AirQuality, policy model.ConflictPolicy) (string, error) {
+	if policy != model.ConflictIgnore && policy != model.ConflictOverwrite {
+		return model.BatchStatusCreated, tx.Omit("Station").Create(airQuality).Error
+	}
+
+	// A concurrent insert that is rolled back after blocking ours leaves no row to
+	// update, so the insert is tried again
+	for attempt := 0; attempt < 3; attempt++ {
+		result := tx.Omit("Station").
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "station_id"}, {Name: "timestamp"}},
+				DoNothing: true,
+			}).
+			Create(airQuality)
+		if result.Error != nil {
+			return "", result.Error
+		}
+		if result.RowsAffected > 0 {
+			return model.BatchStatusCreated, nil
+		}
+
+		var existing model.AirQuality
+		err := tx.Select("id", "created_at").
+			Where("station_id = ? AND timestamp = ?", airQuality.StationID, airQuality.Timestamp).
+			Take(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			airQuality.ID = 0
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		airQuality.ID = existing.ID
+		if policy == model.ConflictIgnore {
+			return model.BatchStatusIgnored, nil
+		}
+		airQuality.CreatedAt = existing.CreatedAt
+		return model.BatchStatusUpdated, tx.Omit("Station").Save(airQuality).Error
+	}
+	return "", fmt.Errorf("reading of station %d at %s kept conflicting", airQuality.StationID, airQuality.Timestamp)
+}
+
+// GetSince returns every reading taken after since, ordered by station and time
+func (r *AirQualityRepository) GetSince(since time.Time) ([]model.AirQuality, error) {
+	var readings []model.AirQuality
+	result := r.db.
+		Where("timestamp >= ?", since).
+		Order("station_id ASC, timestamp ASC").
+		Find(&readings)
+	return readings, result.Error
+}
+
+// UpdateQCFlags stores new QC flags for a reading
+func (r *AirQualityRepository) UpdateQCFlags(id uint, flags model.QCFlags, status model.QCFlag) error {
+	return r.db.Model(&model.AirQuality{}).Where("id = ?", id).
+		Select("qc_pm25", "qc_pm10", "qc_co", "qc_no2", "qc_o3", "qc_so2", "qc_hc", "qc_status").
+		Updates(&model.AirQuality{QCFlags: flags, QCStatus: status}).Error
+}
+
+func (r *AirQualityRepository) GetAverageISPU(qc model.QCFilter) (float64, error) {
 	var avg float64
-	result := r.db.Model(&model.AirQuality{}).
+	result := applyQCFilter(r.db.Model(&model.AirQuality{}), qc, "qc_status").
 		Select("COALESCE(AVG(ispu), 0)").
 		Scan(&avg)
 	return avg, result.Error
@@ -69,22 +360,41 @@ func (r *AirQualityRepository) GetLatestTimestamp() (time.Time, error) {
 	return timestamp, result.Error
 }
 
-func (r *AirQualityRepository) GetCategoryDistribution(categories []model.ISPUCategory) (map[string]int, error) {
+func (r *AirQualityRepository) GetEarliestTimestamp() (*time.Time, error) {
+	var timestamp *time.Time
+	result := r.db.Model(&model.AirQuality{}).
+		Select("MIN(timestamp)").
+		Scan(&timestamp)
+	return timestamp, result.Error
+}
+
+func (r *AirQualityRepository) GetCategoryDistribution(categories []model.ISPUCategory, qc model.QCFilter) (map[string]int, error) {
 	distribution := make(map[string]int)
-	
-	latestData, err := r.GetLatestForAllStations()
+
+	latestData, err := r.GetLatestForAllStations(qc)
 	if err != nil {
 		return distribution, err
 	}
-	
+
 	for _, data := range latestData {
-		category := GetCategoryForISPU(data.ISPU, categories)
+		if data.ISPU == nil {
+			continue
+		}
+		category := GetCategoryForISPU(*data.ISPU, categories)
 		distribution[category]++
 	}
-	
+
 	return distribution, nil
 }
 
+// applyQCFilter restricts a query to readings whose QC status column is accepted by qc
+func applyQCFilter(db *gorm.DB, qc model.QCFilter, column string) *gorm.DB {
+	if len(qc) == 0 {
+		return db
+	}
+	return db.Where(column+" IN ?", []model.QCFlag(qc))
+}
+
 func GetCategoryForISPU(ispu int, categories []model.ISPUCategory) string {
 	for _, cat := range categories {
 		if ispu >= cat.MinValue {
@@ -95,3 +405,31 @@ func GetCategoryForISPU(ispu int, categories []model.ISPUCategory) string {
 	}
 	return "Unknown"
 }
+
+// GetLastSeen returns the timestamp of the latest reading of every active station
+// that has reported, whatever its QC status. Each station is looked up through
+// the (station_id, timestamp) index rather than by grouping the whole table.
+func (r *AirQualityRepository) GetLastSeen() (map[uint]time.Time, error) {
+	var rows []struct {
+		StationID uint
+		LastSeen  *time.Time
+	}
+	result := r.db.Model(&model.Station{}).
+		Select("stations.id AS station_id, (?) AS last_seen",
+			r.db.Model(&model.AirQuality{}).
+				Select("MAX(timestamp)").
+				Where("air_qualities.station_id = stations.id")).
+		Where("stations.is_active = ?", true).
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	lastSeen := make(map[uint]time.Time, len(rows))
+	for _, row := range rows {
+		if row.LastSeen != nil {
+			lastSeen[row.StationID] = *row.LastSeen
+		}
+	}
+	return lastSeen, nil
+}
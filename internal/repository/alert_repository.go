@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"github.com/ispu-monitoring/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+type AlertRepository struct {
+	db *gorm.DB
+}
+
+func NewAlertRepository(db *gorm.DB) *AlertRepository {
+	return &AlertRepository{db: db}
+}
+
+func (r *AlertRepository) ListRules() ([]model.AlertRule, error) {
+	var rules []model.AlertRule
+	result := r.db.Order("id ASC").Find(&rules)
+	return rules, result.Error
+}
+
+// GetEnabledRules returns the enabled rules that may apply to any of the given
+// stations: rules for one of them, for one of their provinces, or network-wide
+func (r *AlertRepository) GetEnabledRules(stationIDs []uint, provinces []string) ([]model.AlertRule, error) {
+	var rules []model.AlertRule
+	result := r.db.
+		Where("enabled = ?", true).
+		Where(r.db.
+			Where("station_id IN ?", stationIDs).
+			Or("province IN ?", provinces).
+			Or("station_id IS NULL AND (province IS NULL OR province = '')")).
+		Find(&rules)
+	return rules, result.Error
+}
+
+func (r *AlertRepository) GetRule(id uint) (*model.AlertRule, error) {
+	var rule model.AlertRule
+	result := r.db.First(&rule, id)
+	return &rule, result.Error
+}
+
+func (r *AlertRepository) CreateRule(rule *model.AlertRule) error {
+	return r.db.Create(rule).Error
+}
+
+// UpdateRule replaces every field of a rule, so a scope or threshold can be cleared
+func (r *AlertRepository) UpdateRule(rule *model.AlertRule) error {
+	return r.db.Save(rule).Error
+}
+
+// DeleteRule removes a rule; its alerts and their history go with it
+func (r *AlertRepository) DeleteRule(id uint) error {
+	result := r.db.Delete(&model.AlertRule{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// GetOpenAlerts returns the pending and firing alerts of the given rules
+func (r *AlertRepository) GetOpenAlerts(ruleIDs []uint) ([]model.Alert, error) {
+	var alerts []model.Alert
+	result := r.db.
+		Where("rule_id IN ? AND state IN ?", ruleIDs, []string{model.AlertPending, model.AlertFiring}).
+		Find(&alerts)
+	return alerts, result.Error
+}
+
+// GetOpenAlert returns the pending or firing alert of a rule at a station
+func (r *AlertRepository) GetOpenAlert(ruleID, stationID uint) (*model.Alert, error) {
+	var alert model.Alert
+	result := r.db.
+		Where("rule_id = ? AND station_id = ? AND state IN ?", ruleID, stationID, []string{model.AlertPending, model.AlertFiring}).
+		First(&alert)
+	return &alert, result.Error
+}
+
+// SaveAlert stores an alert together with the state transitions it went through.
+// Opening a second alert of a rule at a station fails with gorm.ErrDuplicatedKey.
+// afterSave, when set, runs in the same transaction.
+func (r *AlertRepository) SaveAlert(alert *model.Alert, transitions []model.AlertTransition, afterSave func(tx *gorm.DB) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Rule", "Station", "History").Save(alert).Error; err != nil {
+			return err
+		}
+		if len(transitions) > 0 {
+			for i := range transitions {
+				transitions[i].AlertID = alert.ID
+			}
+			if err := tx.Omit("Alert").Create(&transitions).Error; err != nil {
+				return err
+			}
+		}
+		if afterSave == nil {
+			return nil
+		}
+		return afterSave(tx)
+	})
+}
+
+// DeleteAlert removes an alert that never fired
+func (r *AlertRepository) DeleteAlert(id uint) error {
+	return r.db.Delete(&model.Alert{}, id).Error
+}
+
+func (r *AlertRepository) ListAlerts(filter model.AlertFilter) ([]model.Alert, error) {
+	var alerts []model.Alert
+	query := r.db.Preload("Rule").Preload("Station").Order("started_at DESC, id DESC")
+
+	if filter.RuleID != 0 {
+		query = query.Where("rule_id = ?", filter.RuleID)
+	}
+	if filter.StationID != 0 {
+		query = query.Where("station_id = ?", filter.StationID)
+	}
+	if filter.State != "" {
+		query = query.Where("state = ?", filter.State)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	result := query.Find(&alerts)
+	return alerts, result.Error
+}
+
+// GetAlert returns an alert with its rule, station and state history
+func (r *AlertRepository) GetAlert(id uint) (*model.Alert, error) {
+	var alert model.Alert
+	result := r.db.
+		Preload("Rule").
+		Preload("Station").
+		Preload("History", func(db *gorm.DB) *gorm.DB {
+			return db.Order("timestamp ASC, id ASC")
+		}).
+		First(&alert, id)
+	return &alert, result.Error
+}
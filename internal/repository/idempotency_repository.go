@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Reserve claims a key for a new request. When the key is already taken it returns
+// the existing record and false. Records older than ttl are discarded first.
+func (r *IdempotencyRepository) Reserve(key, requestHash string, ttl time.Duration) (*model.IdempotencyKey, bool, error) {
+	if err := r.db.Where("key = ? AND created_at < ?", key, time.Now().Add(-ttl)).
+		Delete(&model.IdempotencyKey{}).Error; err != nil {
+		return nil, false, err
+	}
+
+	record := &model.IdempotencyKey{Key: key, RequestHash: requestHash}
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return record, true, nil
+	}
+
+	var existing model.IdempotencyKey
+	if err := r.db.Where("key = ?", key).First(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+// Complete stores the response sent for a reserved key
+func (r *IdempotencyRepository) Complete(key string, statusCode int, body []byte) error {
+	return r.db.Model(&model.IdempotencyKey{}).Where("key = ?", key).
+		Updates(map[string]interface{}{"status_code": statusCode, "response_body": body}).Error
+}
+
+// DeleteExpired removes the keys reserved before a time
+func (r *IdempotencyRepository) DeleteExpired(before time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", before).Delete(&model.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
+
+// Release frees a reserved key so the request can be retried
+func (r *IdempotencyRepository) Release(key string) error {
+	return r.db.Where("key = ?", key).Delete(&model.IdempotencyKey{}).Error
+}
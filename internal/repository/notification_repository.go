@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+func (r *NotificationRepository) GetSubscribers() ([]model.NotificationSubscriber, error) {
+	var subscribers []model.NotificationSubscriber
+	result := r.db.Order("id ASC").Find(&subscribers)
+	return subscribers, result.Error
+}
+
+// GetEnabledSubscribers returns the enabled subscribers that opted in to alert or
+// digest emails
+func (r *NotificationRepository) GetEnabledSubscribers(kind string) ([]model.NotificationSubscriber, error) {
+	var subscribers []model.NotificationSubscriber
+	query := r.db.Where("enabled = ?", true)
+	switch kind {
+	case model.NotificationAlert:
+		query = query.Where("alert_emails = ?", true)
+	case model.NotificationDigest:
+		query = query.Where("digest_emails = ?", true)
+	}
+	result := query.Find(&subscribers)
+	return subscribers, result.Error
+}
+
+func (r *NotificationRepository) GetSubscriber(id uint) (*model.NotificationSubscriber, error) {
+	var subscriber model.NotificationSubscriber
+	result := r.db.First(&subscriber, id)
+	return &subscriber, result.Error
+}
+
+func (r *NotificationRepository) CreateSubscriber(subscriber *model.NotificationSubscriber) error {
+	return r.db.Create(subscriber).Error
+}
+
+func (r *NotificationRepository) UpdateSubscriber(subscriber *model.NotificationSubscriber) error {
+	return r.db.Save(subscriber).Error
+}
+
+// DeleteSubscriber removes a subscriber together with their notification log
+func (r *NotificationRepository) DeleteSubscriber(id uint) error {
+	result := r.db.Delete(&model.NotificationSubscriber{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// staleClaim is how long a pending notification may go unfinished before another
+// attempt takes it over, for instance after the process died while sending
+const staleClaim = 10 * time.Minute
+
+// Claim records a notification about to be sent. It returns false when the
+// subscriber was already notified under the same reference or another attempt
+// is still sending it. A failed or stale pending notification is claimed again.
+func (r *NotificationRepository) Claim(entry *model.NotificationLog) (bool, error) {
+	result := r.db.Omit("Subscriber").
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "subscriber_id"}, {Name: "reference"}},
+			DoUpdates: clause.AssignmentColumns([]string{"station_id", "kind", "subject", "status", "error", "sent_at", "created_at"}),
+			Where: clause.Where{Exprs: []clause.Expression{clause.Expr{
+				SQL: "notification_logs.status = ? OR (notification_logs.status = ? AND notification_logs.created_at < ?)",
+				Vars: []interface{}{
+					model.NotificationFailed,
+					model.NotificationPending,
+					time.Now().Add(-staleClaim),
+				},
+			}}},
+		}).
+		Create(entry)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Finish stores the outcome of a claimed notification
+func (r *NotificationRepository) Finish(entry *model.NotificationLog) error {
+	return r.db.Model(&model.NotificationLog{}).
+		Where("id = ?", entry.ID).
+		Select("status", "error", "sent_at").
+		Updates(entry).Error
+}
+
+// CountSince counts the alert notifications claimed for a subscriber since a
+// time, optionally only those about one station
+func (r *NotificationRepository) CountSince(subscriberID uint, stationID *uint, since time.Time) (int64, error) {
+	var count int64
+	query := r.db.Model(&model.NotificationLog{}).
+		Where("subscriber_id = ? AND kind = ? AND status <> ? AND created_at >= ?",
+			subscriberID, model.NotificationAlert, model.NotificationFailed, since)
+	if stationID != nil {
+		query = query.Where("station_id = ?", *stationID)
+	}
+	result := query.Count(&count)
+	return count, result.Error
+}
+
+func (r *NotificationRepository) ListLog(filter model.NotificationLogFilter) ([]model.NotificationLog, error) {
+	var entries []model.NotificationLog
+	query := r.db.Order("created_at DESC, id DESC")
+
+	if filter.SubscriberID != 0 {
+		query = query.Where("subscriber_id = ?", filter.SubscriberID)
+	}
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	result := query.Find(&entries)
+	return entries, result.Error
+}
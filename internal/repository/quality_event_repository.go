@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+type QualityEventRepository struct {
+	db *gorm.DB
+}
+
+func NewQualityEventRepository(db *gorm.DB) *QualityEventRepository {
+	return &QualityEventRepository{db: db}
+}
+
+// anomalyScanLock is the advisory lock key held by the instance scanning for anomalies
+const anomalyScanLock = 7407001
+
+// WithScanLock runs fn while holding a database-wide advisory lock, so scans of
+// several instances never record the same detection twice. It returns false
+// without calling fn when another instance is scanning. The lock is released when
+// the transaction holding it ends.
+func (r *QualityEventRepository) WithScanLock(fn func() error) (bool, error) {
+	acquired := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", anomalyScanLock).Scan(&acquired).Error; err != nil {
+			return err
+		}
+		if !acquired {
+			return nil
+		}
+		return fn()
+	})
+	return acquired, err
+}
+
+// FindOverlapping returns the earliest event of the same station, pollutant and
+// type as event whose time range overlaps or touches the event's
+func (r *QualityEventRepository) FindOverlapping(event *model.QualityEvent) (*model.QualityEvent, error) {
+	var existing model.QualityEvent
+	result := r.db.
+		Where("station_id = ? AND pollutant = ? AND type = ?", event.StationID, event.Pollutant, event.Type).
+		Where("start_time <= ? AND end_time >= ?", event.EndTime, event.StartTime).
+		Order("start_time ASC").
+		Take(&existing)
+	return &existing, result.Error
+}
+
+// Save creates a new event or updates the range, count, value and details of an
+// existing one
+func (r *QualityEventRepository) Save(event *model.QualityEvent) error {
+	if event.ID == 0 {
+		return r.db.Omit("Station").Create(event).Error
+	}
+	return r.db.Model(event).
+		Select("start_time", "end_time", "reading_count", "value", "details", "updated_at").
+		Updates(event).Error
+}
+
+func (r *QualityEventRepository) List(filter model.QualityEventFilter) ([]model.QualityEvent, error) {
+	var events []model.QualityEvent
+	query := r.db.Preload("Station").Order("start_time DESC")
+
+	if filter.StationID != 0 {
+		query = query.Where("station_id = ?", filter.StationID)
+	}
+	if filter.Pollutant != "" {
+		query = query.Where("pollutant = ?", filter.Pollutant)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Acknowledged != nil {
+		query = query.Where("acknowledged = ?", *filter.Acknowledged)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	result := query.Find(&events)
+	return events, result.Error
+}
+
+func (r *QualityEventRepository) GetByID(id uint) (*model.QualityEvent, error) {
+	var event model.QualityEvent
+	result := r.db.Preload("Station").First(&event, id)
+	return &event, result.Error
+}
+
+func (r *QualityEventRepository) Acknowledge(id uint) error {
+	return r.db.Model(&model.QualityEvent{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"acknowledged": true, "acknowledged_at": time.Now()}).Error
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+type RollupRepository struct {
+	db *gorm.DB
+}
+
+func NewRollupRepository(db *gorm.DB) *RollupRepository {
+	return &RollupRepository{db: db}
+}
+
+// rollupPeriod describes how raw readings are grouped into a rollup table
+type rollupPeriod struct {
+	table        string
+	bucket       string
+	completeness string
+	// margin widens the raw timestamp filter so buckets at the edges of a range are complete
+	margin time.Duration
+}
+
+var rollupPeriods = map[string]rollupPeriod{
+	model.PeriodHour: {
+		table:  "air_quality_hourly",
+		bucket: "date_trunc('hour', aq.timestamp AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'",
+		// Stations report hourly, so any reading completes the hour
+		completeness: "LEAST(COUNT(*)::float8, 1)",
+		margin:       time.Hour,
+	},
+	model.PeriodDay: {
+		table: "air_quality_daily",
+		// Days follow the station's local calendar
+		bucket:       "date_trunc('day', aq.timestamp AT TIME ZONE s.timezone) AT TIME ZONE s.timezone",
+		completeness: "LEAST(COUNT(DISTINCT date_trunc('hour', timestamp))::float8 / 24, 1)",
+		margin:       24 * time.Hour,
+	},
+}
+
+// Refresh recomputes every bucket of a period starting within [from, to) from the raw
+// readings. A stationID of 0 refreshes all stations.
+func (r *RollupRepository) Refresh(period string, stationID uint, from, to time.Time) error {
+	p, ok := rollupPeriods[period]
+	if !ok {
+		return fmt.Errorf("unknown rollup period %q", period)
+	}
+
+	stationFilter := ""
+	args := map[string]interface{}{
+		"from":      from,
+		"to":        to,
+		"from_wide": from.Add(-p.margin),
+		"to_wide":   to.Add(p.margin),
+	}
+	if stationID != 0 {
+		stationFilter = "AND aq.station_id = @station_id"
+		args["station_id"] = stationID
+	}
+
+	insert := fmt.Sprintf(`
+		WITH src AS (
+			SELECT aq.station_id, v.pollutant, v.value, aq.timestamp, %s AS bucket_start
+			FROM air_qualities aq
+			JOIN stations s ON s.id = aq.station_id
+			CROSS JOIN LATERAL (VALUES
+				('pm25', aq.pm25, aq.qc_pm25),
+				('pm10', aq.pm10, aq.qc_pm10),
+				('co', aq.co, aq.qc_co),
+				('no2', aq.no2, aq.qc_no2),
+				('o3', aq.o3, aq.qc_o3),
+				('so2', aq.so2, aq.qc_so2),
+				('hc', aq.hc, aq.qc_hc),
+				-- The ISPU already leaves invalid pollutants out and is null without a
+				-- valid one, so the worst flag of the reading does not apply to it
+				('ispu', aq.ispu::float8, NULL::text)
+			) AS v(pollutant, value, qc)
+			WHERE aq.timestamp >= @from_wide AND aq.timestamp < @to_wide
+				AND v.value IS NOT NULL
+				AND COALESCE(v.qc, 'valid') <> 'invalid'
+				%s
+		)
+		INSERT INTO %s (station_id, pollutant, bucket_start, mean, min, max, count, completeness, updated_at)
+		SELECT station_id, pollutant, bucket_start, AVG(value), MIN(value), MAX(value), COUNT(*), %s, NOW()
+		FROM src
+		WHERE bucket_start >= @from AND bucket_start < @to
+		GROUP BY station_id, pollutant, bucket_start
+		ON CONFLICT (station_id, pollutant, bucket_start) DO UPDATE SET
+			mean = EXCLUDED.mean,
+			min = EXCLUDED.min,
+			max = EXCLUDED.max,
+			count = EXCLUDED.count,
+			completeness = EXCLUDED.completeness,
+			updated_at = EXCLUDED.updated_at
+	`, p.bucket, stationFilter, p.table, p.completeness)
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Buckets whose readings were all removed or invalidated must disappear too
+		remove := tx.Table(p.table).Where("bucket_start >= ? AND bucket_start < ?", from, to)
+		if stationID != 0 {
+			remove = remove.Where("station_id = ?", stationID)
+		}
+		if err := remove.Delete(&model.AirQualityAggregate{}).Error; err != nil {
+			return err
+		}
+		return tx.Exec(insert, args).Error
+	})
+}
+
+// List returns the buckets of a period for a station, optionally limited to one pollutant
+func (r *RollupRepository) List(period string, stationID uint, pollutant model.Pollutant, from, to time.Time) ([]model.AirQualityAggregate, error) {
+	p, ok := rollupPeriods[period]
+	if !ok {
+		return nil, fmt.Errorf("unknown rollup period %q", period)
+	}
+
+	var aggregates []model.AirQualityAggregate
+	query := r.db.Table(p.table).
+		Where("station_id = ? AND bucket_start >= ? AND bucket_start < ?", stationID, from, to)
+	if pollutant != "" {
+		query = query.Where("pollutant = ?", pollutant)
+	}
+	result := query.Order("bucket_start ASC, pollutant ASC").Find(&aggregates)
+	return aggregates, result.Error
+}
+
+// ListAll returns the buckets of a period for every station
+func (r *RollupRepository) ListAll(period string, from, to time.Time) ([]model.AirQualityAggregate, error) {
+	p, ok := rollupPeriods[period]
+	if !ok {
+		return nil, fmt.Errorf("unknown rollup period %q", period)
+	}
+
+	var aggregates []model.AirQualityAggregate
+	result := r.db.Table(p.table).
+		Where("bucket_start >= ? AND bucket_start < ?", from, to).
+		Order("station_id ASC, bucket_start ASC").
+		Find(&aggregates)
+	return aggregates, result.Error
+}
+
+// IsEmpty reports whether the daily rollup has never been built
+func (r *RollupRepository) IsEmpty() (bool, error) {
+	var count int64
+	result := r.db.Model(&model.DailyAggregate{}).Limit(1).Count(&count)
+	return count == 0, result.Error
+}
+
+// GetAverageISPU averages the ISPU of every valid reading ever recorded using the
+// daily rollup instead of scanning the raw readings
+func (r *RollupRepository) GetAverageISPU() (float64, error) {
+	var avg float64
+	result := r.db.Model(&model.DailyAggregate{}).
+		Where("pollutant = ?", model.ParameterISPU).
+		Select("COALESCE(SUM(mean * count) / NULLIF(SUM(count), 0), 0)").
+		Scan(&avg)
+	return avg, result.Error
+}
@@ -1,6 +1,10 @@
 package repository
 
 import (
+	"math"
+	"strings"
+	"time"
+
 	"github.com/ispu-monitoring/backend/internal/model"
 	"gorm.io/gorm"
 )
@@ -19,12 +23,71 @@ func (r *StationRepository) GetAll() ([]model.Station, error) {
 	return stations, result.Error
 }
 
+// stationSortColumns are the station fields lists may be sorted by
+var stationSortColumns = map[string]string{
+	"name":       "name",
+	"code":       "code",
+	"province":   "province",
+	"city":       "city",
+	"created_at": "created_at",
+}
+
+// IsStationSortKey reports whether a sort value (optionally prefixed with "-" for
+// descending order) names a sortable station field
+func IsStationSortKey(value string) bool {
+	_, ok := stationSortColumns[strings.TrimPrefix(value, "-")]
+	return ok
+}
+
+// List returns the active stations matching a filter, sorted with the id breaking
+// ties, together with the number of matching stations
+func (r *StationRepository) List(filter model.StationFilter) ([]model.Station, int64, error) {
+	query := r.db.Model(&model.Station{}).Where("is_active = ?", true)
+	if filter.Province != "" {
+		query = query.Where("province = ?", filter.Province)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	direction := "ASC"
+	if strings.HasPrefix(filter.Sort, "-") {
+		direction = "DESC"
+	}
+	column, ok := stationSortColumns[strings.TrimPrefix(filter.Sort, "-")]
+	if !ok {
+		column = "name"
+	}
+	query = query.Order(column + " " + direction + ", id " + direction)
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	var stations []model.Station
+	result := query.Find(&stations)
+	return stations, total, result.Error
+}
+
 func (r *StationRepository) GetByID(id uint) (*model.Station, error) {
 	var station model.Station
 	result := r.db.First(&station, id)
 	return &station, result.Error
 }
 
+func (r *StationRepository) GetByCode(code string) (*model.Station, error) {
+	var station model.Station
+	result := r.db.Where("code = ?", code).First(&station)
+	return &station, result.Error
+}
+
 func (r *StationRepository) GetByProvince(province string) ([]model.Station, error) {
 	var stations []model.Station
 	result := r.db.Where("province = ? AND is_active = ?", province, true).Find(&stations)
@@ -63,3 +126,83 @@ func (r *StationRepository) GetProvinces() ([]string, error) {
 		Pluck("province", &provinces)
 	return provinces, result.Error
 }
+
+// earthRadiusKm is the mean Earth radius used for great-circle distances
+const earthRadiusKm = 6371.0
+
+// GetNearby returns up to limit active stations within radiusKm of a point, nearest
+// first by haversine distance. A bounding box served by the (latitude, longitude)
+// index narrows the candidates before any distance is computed.
+func (r *StationRepository) GetNearby(lat, lon, radiusKm float64, limit int) ([]model.NearbyStation, error) {
+	latDelta := radiusKm / earthRadiusKm * 180 / math.Pi
+	lonDelta := 180.0
+	if cos := math.Cos(lat * math.Pi / 180); cos > 0.01 {
+		lonDelta = math.Min(latDelta/cos, 180)
+	}
+
+	candidates := r.db.Model(&model.Station{}).
+		Select(`stations.*, @radius * 2 * ASIN(SQRT(
+			POWER(SIN(RADIANS(latitude - @lat) / 2), 2) +
+			COS(RADIANS(@lat)) * COS(RADIANS(latitude)) * POWER(SIN(RADIANS(longitude - @lon) / 2), 2)
+		)) AS distance_km`, map[string]interface{}{"radius": earthRadiusKm, "lat": lat, "lon": lon}).
+		Where("is_active = ?", true).
+		Where("latitude BETWEEN ? AND ?", lat-latDelta, lat+latDelta).
+		Where("longitude BETWEEN ? AND ?", lon-lonDelta, lon+lonDelta)
+
+	var stations []model.NearbyStation
+	result := r.db.Table("(?) AS nearby", candidates).
+		Where("distance_km <= ?", radiusKm).
+		Order("distance_km ASC").
+		Limit(limit).
+		Scan(&stations)
+	return stations, result.Error
+}
+
+// UpdateLastSeen stores the time of a station's latest reading without touching
+// updated_at
+func (r *StationRepository) UpdateLastSeen(id uint, lastSeen *time.Time) error {
+	return r.db.Model(&model.Station{}).Where("id = ?", id).UpdateColumn("last_seen_at", lastSeen).Error
+}
+
+// ChangeStatus moves a station from its previous to a new reporting status and
+// records the transition. Nothing is written when the stored status is no longer
+// the previous one, so monitors running on several instances record each change
+// once; the result reports whether this call recorded it. afterSave, when set,
+// runs in the same transaction once the transition is recorded.
+func (r *StationRepository) ChangeStatus(transition *model.StationStatusTransition, afterSave func(tx *gorm.DB) error) (bool, error) {
+	changed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Station{}).
+			Where("id = ? AND status = ?", transition.StationID, transition.PreviousStatus).
+			UpdateColumns(map[string]interface{}{
+				"status":            transition.Status,
+				"last_seen_at":      transition.LastSeenAt,
+				"status_changed_at": transition.ChangedAt,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		if err := tx.Omit("Station").Create(transition).Error; err != nil {
+			return err
+		}
+		if afterSave != nil {
+			if err := afterSave(tx); err != nil {
+				return err
+			}
+		}
+		changed = true
+		return nil
+	})
+	return changed, err
+}
+
+// GetStatusHistory returns a station's status transitions, newest first
+func (r *StationRepository) GetStatusHistory(stationID uint, limit int) ([]model.StationStatusTransition, error) {
+	var transitions []model.StationStatusTransition
+	query := r.db.Where("station_id = ?", stationID).Order("changed_at DESC, id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	result := query.Find(&transitions)
+	return transitions, result.Error
+}
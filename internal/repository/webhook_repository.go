@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// WithTx returns a repository that works within tx
+func (r *WebhookRepository) WithTx(tx *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: tx}
+}
+
+func (r *WebhookRepository) GetAll() ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	result := r.db.Order("id ASC").Find(&webhooks)
+	return webhooks, result.Error
+}
+
+func (r *WebhookRepository) GetEnabled() ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	result := r.db.Where("enabled = ?", true).Find(&webhooks)
+	return webhooks, result.Error
+}
+
+func (r *WebhookRepository) GetByID(id uint) (*model.Webhook, error) {
+	var webhook model.Webhook
+	result := r.db.First(&webhook, id)
+	return &webhook, result.Error
+}
+
+func (r *WebhookRepository) Create(webhook *model.Webhook) error {
+	return r.db.Create(webhook).Error
+}
+
+func (r *WebhookRepository) Update(webhook *model.Webhook) error {
+	return r.db.Save(webhook).Error
+}
+
+// Delete removes a webhook together with its deliveries
+func (r *WebhookRepository) Delete(id uint) error {
+	result := r.db.Delete(&model.Webhook{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// Enqueue adds deliveries to the outbox
+func (r *WebhookRepository) Enqueue(deliveries []model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.Omit("Webhook").Create(&deliveries).Error
+}
+
+// ClaimDue locks up to limit pending deliveries that are due and pushes their next
+// attempt past lease, so concurrent workers on other instances skip them while
+// they are being sent. The deliveries are returned with their webhook.
+func (r *WebhookRepository) ClaimDue(now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	var ids []uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.WebhookDelivery{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", model.DeliveryPending, now).
+			Order("next_attempt_at ASC").
+			Limit(limit).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		return tx.Model(&model.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	// Preloading inside the locking query would lock the webhooks too
+	var deliveries []model.WebhookDelivery
+	err = r.db.Preload("Webhook").Where("id IN ?", ids).Order("next_attempt_at ASC, id ASC").Find(&deliveries).Error
+	return deliveries, err
+}
+
+// SaveAttempt stores the outcome of a delivery attempt
+func (r *WebhookRepository) SaveAttempt(delivery *model.WebhookDelivery) error {
+	return r.db.Model(&model.WebhookDelivery{}).
+		Where("id = ?", delivery.ID).
+		Select("status", "attempts", "next_attempt_at", "last_attempt_at", "response_status", "response_body", "last_error", "delivered_at", "updated_at").
+		Updates(delivery).Error
+}
+
+func (r *WebhookRepository) ListDeliveries(filter model.WebhookDeliveryFilter) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	query := r.db.Where("webhook_id = ?", filter.WebhookID).Order("created_at DESC, id DESC")
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	result := query.Find(&deliveries)
+	return deliveries, result.Error
+}
+
+// DeleteFinishedBefore prunes delivered and failed deliveries created before a time
+func (r *WebhookRepository) DeleteFinishedBefore(before time.Time) (int64, error) {
+	result := r.db.
+		Where("status IN ? AND created_at < ?", []string{model.DeliveryDelivered, model.DeliveryFailed}, before).
+		Delete(&model.WebhookDelivery{})
+	return result.RowsAffected, result.Error
+}
@@ -3,33 +3,48 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"sort"
 	"time"
 
 	"github.com/ispu-monitoring/backend/internal/model"
 	"github.com/ispu-monitoring/backend/internal/repository"
 	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
 type AirQualityService struct {
-	repo        *repository.AirQualityRepository
-	stationRepo *repository.StationRepository
-	redis       *redis.Client
+	repo         *repository.AirQualityRepository
+	stationRepo  *repository.StationRepository
+	categoryRepo *repository.CategoryRepository
+	rollups      *RollupService
+	events       *EventBroker
+	alerts       *AlertService
+	redis        *redis.Client
 }
 
-func NewAirQualityService(repo *repository.AirQualityRepository, stationRepo *repository.StationRepository, redis *redis.Client) *AirQualityService {
+func NewAirQualityService(repo *repository.AirQualityRepository, stationRepo *repository.StationRepository, categoryRepo *repository.CategoryRepository, rollups *RollupService, events *EventBroker, alerts *AlertService, redis *redis.Client) *AirQualityService {
 	return &AirQualityService{
-		repo:        repo,
-		stationRepo: stationRepo,
-		redis:       redis,
+		repo:         repo,
+		stationRepo:  stationRepo,
+		categoryRepo: categoryRepo,
+		rollups:      rollups,
+		events:       events,
+		alerts:       alerts,
+		redis:        redis,
 	}
 }
 
-func (s *AirQualityService) GetLatestData() ([]model.AirQuality, error) {
+// GetLatestData returns the latest reading of every station. Only unfiltered
+// results are cached.
+func (s *AirQualityService) GetLatestData(qc model.QCFilter) ([]model.AirQuality, error) {
 	// Try cache first
-	if s.redis != nil {
+	if s.redis != nil && len(qc) == 0 {
 		cacheKey := "air_quality:latest"
 		ctx := context.Background()
-		
+
 		cached, err := s.redis.Get(ctx, cacheKey).Result()
 		if err == nil {
 			var data []model.AirQuality
@@ -37,37 +52,446 @@ func (s *AirQualityService) GetLatestData() ([]model.AirQuality, error) {
 				return data, nil
 			}
 		}
-		
+
 		// Fetch from database
-		data, err := s.repo.GetLatestForAllStations()
+		data, err := s.repo.GetLatestForAllStations(qc)
 		if err != nil {
 			return nil, err
 		}
-		
+
 		// Cache for 2 minutes
 		jsonData, _ := json.Marshal(data)
 		s.redis.Set(ctx, cacheKey, jsonData, 2*time.Minute)
-		
+
 		return data, nil
 	}
-	
-	return s.repo.GetLatestForAllStations()
+
+	return s.repo.GetLatestForAllStations(qc)
+}
+
+func (s *AirQualityService) GetStationLatestData(stationID uint, qc model.QCFilter) (*model.AirQuality, error) {
+	return s.repo.GetLatestByStationID(stationID, qc)
+}
+
+// GetLatestDataPage returns one keyset page of the latest reading of every station.
+// Without a limit or cursor the latest readings of all stations are returned.
+func (s *AirQualityService) GetLatestDataPage(qc model.QCFilter, page model.PageRequest) ([]model.AirQuality, *model.Pagination, error) {
+	if page.Limit == 0 && page.Cursor == nil {
+		data, err := s.GetLatestData(qc)
+		if err != nil {
+			return nil, nil, err
+		}
+		items := sortMeasurements(data, page.Ascending)
+		total := len(items)
+		return items, &model.Pagination{Limit: total, Total: &total}, nil
+	}
+
+	data, total, err := s.repo.GetLatestPage(qc, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	items, pagination := trimPage(data, page.Limit)
+	count := int(total)
+	pagination.Total = &count
+	return items, pagination, nil
+}
+
+// GetHistoricalData returns one keyset page of a station's readings between two dates
+func (s *AirQualityService) GetHistoricalData(stationID uint, startDate, endDate time.Time, qc model.QCFilter, page model.PageRequest) ([]model.AirQuality, *model.Pagination, error) {
+	history, err := s.repo.GetHistoryByStationID(stationID, startDate, endDate, qc, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	items, pagination := trimPage(history, page.Limit)
+	return items, pagination, nil
+}
+
+// GetBucketedHistory downsamples a station's history into interval buckets aligned
+// to local time in loc using the agg aggregation, and categorizes each bucket by
+// its aggregated ISPU
+func (s *AirQualityService) GetBucketedHistory(stationID uint, startDate, endDate time.Time, interval, agg string, qc model.QCFilter, loc *time.Location) ([]model.AirQualityBucket, error) {
+	buckets, err := s.repo.GetBucketedHistory(stationID, startDate, endDate, interval, agg, loc.String(), qc)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, _ := s.categoryRepo.GetAll()
+	for i := range buckets {
+		if buckets[i].ISPU != nil {
+			buckets[i].Category, buckets[i].Color = categorize(int(math.Round(*buckets[i].ISPU)), categories)
+		}
+	}
+	return buckets, nil
+}
+
+// InsertAirQuality stores a reading and returns it together with the batch status
+// telling whether it was created, updated or ignored under the conflict policy.
+func (s *AirQualityService) InsertAirQuality(input *model.AirQualityInput, policy model.ConflictPolicy) (*model.AirQuality, string, error) {
+	stations := s.newStationResolver()
+	data, err := s.buildAirQuality(input, stations)
+	if err != nil {
+		return nil, "", err
+	}
+
+	announcer := s.newAnnouncer(stations)
+	status, err := s.repo.Save(data, policy, announcer.stage)
+	if err != nil {
+		return nil, "", translateSaveError(err)
+	}
+	if status != model.BatchStatusIgnored {
+		// Invalidated only once the reading is committed, so a concurrent read
+		// cannot cache the data from before the insert
+		s.invalidateCache()
+		s.rollups.RefreshReadings([]*model.AirQuality{data})
+		latest := announcer.publish([]*model.AirQuality{data})
+		s.alerts.Evaluate(latest, stations.byID)
+	}
+	return data, status, nil
+}
+
+// InsertAirQualityBatch stores many readings at once and reports the outcome of
+// each input at the same position. Caches are invalidated once for the whole batch.
+func (s *AirQualityService) InsertAirQualityBatch(inputs []model.AirQualityInput, policy model.ConflictPolicy) []model.BatchRowResult {
+	results := make([]model.BatchRowResult, len(inputs))
+	items := make([]*model.AirQuality, 0, len(inputs))
+	positions := make([]int, 0, len(inputs))
+	stations := s.newStationResolver()
+
+	for i := range inputs {
+		results[i].Index = i
+		data, err := s.buildAirQuality(&inputs[i], stations)
+		if err != nil {
+			results[i].Status = model.BatchStatusFailed
+			results[i].Error = batchError(err)
+			continue
+		}
+		items = append(items, data)
+		positions = append(positions, i)
+	}
+
+	if len(items) == 0 {
+		return results
+	}
+
+	// Saved oldest first, so each reading's category change is judged against the
+	// reading before it
+	sort.Stable(readingsByTime{items, positions})
+	announcer := s.newAnnouncer(stations)
+	statuses, errs := s.repo.SaveBatch(items, policy, batchChunkSize, announcer.stage)
+	stored := make([]*model.AirQuality, 0, len(items))
+	for j, err := range errs {
+		i := positions[j]
+		if err != nil {
+			results[i].Status = model.BatchStatusFailed
+			results[i].Error = batchError(translateSaveError(err))
+			continue
+		}
+		results[i].Status = statuses[j]
+		results[i].ID = items[j].ID
+		results[i].ISPU = items[j].ISPU
+		if statuses[j] != model.BatchStatusIgnored {
+			stored = append(stored, items[j])
+		}
+	}
+
+	if len(stored) > 0 {
+		s.invalidateCache()
+	}
+	s.rollups.RefreshReadings(stored)
+	latest := announcer.publish(stored)
+	s.alerts.Evaluate(latest, stations.byID)
+	return results
 }
 
-func (s *AirQualityService) GetStationLatestData(stationID uint) (*model.AirQuality, error) {
-	return s.repo.GetLatestByStationID(stationID)
+// readingsByTime orders readings by timestamp together with their input positions
+type readingsByTime struct {
+	items     []*model.AirQuality
+	positions []int
 }
 
-func (s *AirQualityService) GetHistoricalData(stationID uint, startDate, endDate time.Time) ([]model.AirQuality, error) {
-	return s.repo.GetHistoryByStationID(stationID, startDate, endDate)
+func (r readingsByTime) Len() int { return len(r.items) }
+
+func (r readingsByTime) Less(i, j int) bool {
+	return r.items[i].Timestamp.Before(r.items[j].Timestamp)
 }
 
-func (s *AirQualityService) InsertAirQuality(data *model.AirQuality) error {
-	// Invalidate cache
+func (r readingsByTime) Swap(i, j int) {
+	r.items[i], r.items[j] = r.items[j], r.items[i]
+	r.positions[i], r.positions[j] = r.positions[j], r.positions[i]
+}
+
+// announcer works out the live events of saved readings: every reading, and a
+// category change for a reading that became its station's latest in a different
+// ISPU category than the reading before. Backfilled older readings never change
+// the category. Readings without an ISPU, left by quality control without any
+// valid pollutant, have no category and are neither announced nor taken as the
+// latest.
+type announcer struct {
+	service    *AirQualityService
+	stations   *stationResolver
+	categories []model.ISPUCategory
+	announced  map[*model.AirQuality]announcement
+}
+
+type announcement struct {
+	events []model.LiveEvent
+	latest bool
+}
+
+func (s *AirQualityService) newAnnouncer(stations *stationResolver) *announcer {
+	categories, _ := s.categoryRepo.GetAll()
+	return &announcer{
+		service:    s,
+		stations:   stations,
+		categories: categories,
+		announced:  make(map[*model.AirQuality]announcement),
+	}
+}
+
+// stage works out the events of a reading within tx, the transaction saving it,
+// and stages them in the event outbox
+func (a *announcer) stage(tx *gorm.DB, data *model.AirQuality) error {
+	station := a.stations.byID[data.StationID]
+	if station == nil || !hasISPU(data) {
+		return nil
+	}
+	reading := *data
+	reading.Station = station
+	live := toStationWithAirQuality(reading, a.categories)
+
+	result := announcement{events: []model.LiveEvent{{
+		Type:        model.LiveEventReading,
+		StationID:   station.ID,
+		StationCode: station.Code,
+		Province:    station.Province,
+		Timestamp:   data.Timestamp,
+		Data:        live,
+	}}}
+
+	// The reading before, including those saved earlier in the same transaction
+	before, err := a.service.repo.WithTx(tx).GetLatestExcept(data.StationID, data.ID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		result.latest = true
+	case err != nil:
+		return err
+	case !data.Timestamp.Before(before.Timestamp):
+		result.latest = true
+		previousCategory, _ := categorize(*before.ISPU, a.categories)
+		if previousCategory != live.Category {
+			result.events = append(result.events, model.LiveEvent{
+				Type:        model.LiveEventCategoryChange,
+				StationID:   station.ID,
+				StationCode: station.Code,
+				Province:    station.Province,
+				Timestamp:   data.Timestamp,
+				Data: model.CategoryChange{
+					StationName:       station.Name,
+					PreviousCategory:  previousCategory,
+					PreviousISPU:      *before.ISPU,
+					Category:          live.Category,
+					Color:             live.Color,
+					ISPU:              *data.ISPU,
+					CriticalPollutant: data.CriticalPollutant,
+					Timestamp:         data.Timestamp,
+				},
+			})
+		}
+	}
+
+	if err := a.service.events.Stage(tx, result.events); err != nil {
+		return err
+	}
+	a.announced[data] = result
+	return nil
+}
+
+// publish announces the events of committed readings to live clients and returns
+// the readings that became their station's latest, oldest first
+func (a *announcer) publish(stored []*model.AirQuality) []*model.AirQuality {
+	ordered := make([]*model.AirQuality, len(stored))
+	copy(ordered, stored)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Timestamp.Before(ordered[j].Timestamp)
+	})
+
+	var latest []*model.AirQuality
+	for _, data := range ordered {
+		result, ok := a.announced[data]
+		if !ok {
+			continue
+		}
+		for _, event := range result.events {
+			a.service.events.Publish(event)
+		}
+		if result.latest {
+			latest = append(latest, data)
+		}
+	}
+	return latest
+}
+
+// hasISPU reports whether a reading has an ISPU, i.e. quality control left at least
+// one valid pollutant
+func hasISPU(data *model.AirQuality) bool {
+	return data.ISPU != nil
+}
+
+// batchChunkSize is the number of rows written per transaction in batch uploads
+const batchChunkSize = 500
+
+func batchError(err error) *model.APIError {
+	var ingestErr *IngestError
+	if errors.As(err, &ingestErr) {
+		return &model.APIError{Code: ingestErr.Code, Message: ingestErr.Message}
+	}
+	return &model.APIError{Code: "INSERT_ERROR", Message: "Failed to insert air quality data", Details: err.Error()}
+}
+
+func translateSaveError(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicateReading
+	}
+	return err
+}
+
+func (s *AirQualityService) invalidateCache() {
 	if s.redis != nil {
 		ctx := context.Background()
 		s.redis.Del(ctx, "air_quality:latest")
 		s.redis.Del(ctx, "dashboard:overview")
+		s.redis.Del(ctx, "map:stations")
+	}
+}
+
+// buildAirQuality converts a device payload into a reading with a server-computed ISPU
+func (s *AirQualityService) buildAirQuality(input *model.AirQualityInput, stations *stationResolver) (*model.AirQuality, error) {
+	station, err := stations.resolve(input)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &model.AirQuality{
+		StationID: station.ID,
+		PM25:      input.PM25,
+		PM10:      input.PM10,
+		CO:        input.CO,
+		NO2:       input.NO2,
+		O3:        input.O3,
+		SO2:       input.SO2,
+		HC:        input.HC,
+		Timestamp: input.Timestamp,
+	}
+
+	// Set timestamp if not provided
+	if data.Timestamp.IsZero() {
+		data.Timestamp = time.Now()
+	}
+	if data.Timestamp.After(time.Now().Add(futureTolerance)) {
+		return nil, ErrFutureTimestamp
+	}
+	// Readings are unique per station and second
+	data.Timestamp = data.Timestamp.Truncate(time.Second)
+
+	if !hasConcentration(data) {
+		return nil, ErrNoPollutants
+	}
+
+	ScoreAirQuality(data)
+
+	// Keep a disagreeing client value for review instead of trusting it
+	if input.ISPU != nil && data.ISPU != nil && *input.ISPU != *data.ISPU {
+		reported := *input.ISPU
+		data.ReportedISPU = &reported
+		data.ISPUMismatch = true
+		log.Printf("Warning: station %d reported ISPU %d but computed ISPU is %d", data.StationID, reported, *data.ISPU)
 	}
-	return s.repo.Create(data)
+
+	return data, nil
+}
+
+func hasConcentration(data *model.AirQuality) bool {
+	for _, pollutant := range model.Pollutants {
+		if data.Concentration(pollutant) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// stationResolver looks up the station of incoming readings, remembering stations
+// already seen so a batch does not query the same station repeatedly
+type stationResolver struct {
+	repo   *repository.StationRepository
+	byID   map[uint]*model.Station
+	byCode map[string]*model.Station
+}
+
+func (s *AirQualityService) newStationResolver() *stationResolver {
+	return &stationResolver{
+		repo:   s.stationRepo,
+		byID:   make(map[uint]*model.Station),
+		byCode: make(map[string]*model.Station),
+	}
+}
+
+// resolve finds the active station referenced by station_code or station_id
+func (r *stationResolver) resolve(input *model.AirQualityInput) (*model.Station, error) {
+	var station *model.Station
+	var err error
+
+	switch {
+	case input.StationCode != "":
+		station, err = r.byCodeLookup(input.StationCode)
+	case input.StationID != 0:
+		station, err = r.byIDLookup(input.StationID)
+	default:
+		return nil, ErrStationRequired
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if input.StationID != 0 && input.StationID != station.ID {
+		return nil, ErrStationMismatch
+	}
+	if !station.IsActive {
+		return nil, ErrStationInactive
+	}
+	return station, nil
+}
+
+func (r *stationResolver) byCodeLookup(code string) (*model.Station, error) {
+	if station, ok := r.byCode[code]; ok {
+		return station, nil
+	}
+	station, err := r.repo.GetByCode(code)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrStationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.remember(station)
+	return station, nil
+}
+
+func (r *stationResolver) byIDLookup(id uint) (*model.Station, error) {
+	if station, ok := r.byID[id]; ok {
+		return station, nil
+	}
+	station, err := r.repo.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrStationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.remember(station)
+	return station, nil
+}
+
+func (r *stationResolver) remember(station *model.Station) {
+	r.byID[station.ID] = station
+	r.byCode[station.Code] = station
 }
@@ -0,0 +1,464 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// AlertService manages alert rules and evaluates them against incoming readings.
+// An alert starts pending on the first reading at or above its rule's threshold,
+// fires once the breach has lasted the rule's duration, and resolves when a
+// reading falls below the threshold minus the hysteresis. A pending alert whose
+// station recovers first is discarded.
+type AlertService struct {
+	repo         *repository.AlertRepository
+	stationRepo  *repository.StationRepository
+	categoryRepo *repository.CategoryRepository
+	events       *EventBroker
+}
+
+func NewAlertService(repo *repository.AlertRepository, stationRepo *repository.StationRepository, categoryRepo *repository.CategoryRepository, events *EventBroker) *AlertService {
+	return &AlertService{
+		repo:         repo,
+		stationRepo:  stationRepo,
+		categoryRepo: categoryRepo,
+		events:       events,
+	}
+}
+
+func (s *AlertService) ListRules() ([]model.AlertRule, error) {
+	return s.repo.ListRules()
+}
+
+func (s *AlertService) GetRule(id uint) (*model.AlertRule, error) {
+	return s.repo.GetRule(id)
+}
+
+func (s *AlertService) CreateRule(rule *model.AlertRule) error {
+	if err := s.validateRule(rule); err != nil {
+		return err
+	}
+	return s.repo.CreateRule(rule)
+}
+
+// UpdateRule replaces a rule. Open alerts of a rule being disabled are closed:
+// firing ones resolve and pending ones are discarded.
+func (s *AlertService) UpdateRule(id uint, rule *model.AlertRule) (*model.AlertRule, error) {
+	existing, err := s.repo.GetRule(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateRule(rule); err != nil {
+		return nil, err
+	}
+
+	rule.ID = existing.ID
+	rule.CreatedAt = existing.CreatedAt
+	if err := s.repo.UpdateRule(rule); err != nil {
+		return nil, err
+	}
+
+	if !*rule.Enabled && *existing.Enabled {
+		s.closeAlerts(rule, time.Now())
+	}
+	return rule, nil
+}
+
+func (s *AlertService) DeleteRule(id uint) error {
+	return s.repo.DeleteRule(id)
+}
+
+func (s *AlertService) ListAlerts(filter model.AlertFilter) ([]model.Alert, error) {
+	return s.repo.ListAlerts(filter)
+}
+
+func (s *AlertService) GetAlert(id uint) (*model.Alert, error) {
+	return s.repo.GetAlert(id)
+}
+
+// validateRule checks a rule and normalizes its category name
+func (s *AlertService) validateRule(rule *model.AlertRule) error {
+	if !isRuleParameter(rule.Parameter) {
+		return ErrRuleParameter
+	}
+	if (rule.Threshold == nil) == (rule.Category == "") {
+		return ErrRuleThreshold
+	}
+	if rule.StationID != nil && rule.Province != "" {
+		return ErrRuleScope
+	}
+	if rule.DurationMinutes < 0 {
+		return ErrRuleDuration
+	}
+	if rule.Hysteresis < 0 {
+		return ErrRuleHysteresis
+	}
+
+	if rule.Category != "" {
+		categories, err := s.categoryRepo.GetAll()
+		if err != nil {
+			return err
+		}
+		category := findCategory(rule.Category, categories)
+		if category == nil {
+			return ErrRuleCategory
+		}
+		rule.Category = category.Category
+	}
+
+	if rule.StationID != nil {
+		_, err := s.stationRepo.GetByID(*rule.StationID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRuleStation
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if rule.Enabled == nil {
+		enabled := true
+		rule.Enabled = &enabled
+	}
+	return nil
+}
+
+func isRuleParameter(parameter model.Pollutant) bool {
+	if parameter == model.ParameterISPU {
+		return true
+	}
+	for _, pollutant := range model.Pollutants {
+		if parameter == pollutant {
+			return true
+		}
+	}
+	return false
+}
+
+// findCategory looks up an ISPU category by name, ignoring case
+func findCategory(name string, categories []model.ISPUCategory) *model.ISPUCategory {
+	for i := range categories {
+		if strings.EqualFold(categories[i].Category, name) {
+			return &categories[i]
+		}
+	}
+	return nil
+}
+
+// ruleThreshold returns the value a rule compares readings against: its threshold,
+// or the lowest ISPU of its category
+func ruleThreshold(rule *model.AlertRule, categories []model.ISPUCategory) (float64, bool) {
+	if rule.Threshold != nil {
+		return *rule.Threshold, true
+	}
+	category := findCategory(rule.Category, categories)
+	if category == nil {
+		return 0, false
+	}
+	return float64(category.MinValue), true
+}
+
+// ruleValue returns the value of a reading that a rule watches. Category rules
+// compare the ISPU or a pollutant's sub-index, threshold rules the ISPU or a
+// pollutant's concentration. Values missing or flagged invalid are skipped.
+func ruleValue(rule *model.AlertRule, data *model.AirQuality) (float64, bool) {
+	if rule.Parameter == model.ParameterISPU {
+		// Readings without any valid pollutant carry no ISPU
+		if data.ISPU == nil {
+			return 0, false
+		}
+		return float64(*data.ISPU), true
+	}
+
+	if rule.Category != "" {
+		subIndex := data.SubIndices.Get(rule.Parameter)
+		if subIndex == nil {
+			return 0, false
+		}
+		return float64(*subIndex), true
+	}
+
+	concentration := data.Concentration(rule.Parameter)
+	if concentration == nil || data.QCFlags.Get(rule.Parameter) == model.QCInvalid {
+		return 0, false
+	}
+	return *concentration, true
+}
+
+// ruleApplies reports whether a station is in a rule's scope
+func ruleApplies(rule *model.AlertRule, station *model.Station) bool {
+	switch {
+	case rule.StationID != nil:
+		return *rule.StationID == station.ID
+	case rule.Province != "":
+		return rule.Province == station.Province
+	}
+	return true
+}
+
+type alertKey struct {
+	ruleID    uint
+	stationID uint
+}
+
+// Evaluate runs the enabled rules against newly stored readings that became their
+// station's latest. Failures are logged and never fail the ingest.
+func (s *AlertService) Evaluate(readings []*model.AirQuality, stations map[uint]*model.Station) {
+	if len(readings) == 0 {
+		return
+	}
+
+	stationIDs := make([]uint, 0, len(stations))
+	provinces := make([]string, 0, len(stations))
+	for _, station := range stations {
+		stationIDs = append(stationIDs, station.ID)
+		provinces = append(provinces, station.Province)
+	}
+
+	rules, err := s.repo.GetEnabledRules(stationIDs, provinces)
+	if err != nil {
+		log.Printf("Error loading alert rules: %v", err)
+		return
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	categories, err := s.categoryRepo.GetAll()
+	if err != nil {
+		log.Printf("Error loading ISPU categories for alerts: %v", err)
+		return
+	}
+
+	ruleIDs := make([]uint, len(rules))
+	for i := range rules {
+		ruleIDs[i] = rules[i].ID
+	}
+	openAlerts, err := s.repo.GetOpenAlerts(ruleIDs)
+	if err != nil {
+		log.Printf("Error loading open alerts: %v", err)
+		return
+	}
+	open := make(map[alertKey]*model.Alert, len(openAlerts))
+	for i := range openAlerts {
+		open[alertKey{openAlerts[i].RuleID, openAlerts[i].StationID}] = &openAlerts[i]
+	}
+
+	ordered := make([]*model.AirQuality, len(readings))
+	copy(ordered, readings)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Timestamp.Before(ordered[j].Timestamp)
+	})
+
+	for _, data := range ordered {
+		station := stations[data.StationID]
+		if station == nil {
+			continue
+		}
+		for i := range rules {
+			rule := &rules[i]
+			if !ruleApplies(rule, station) {
+				continue
+			}
+			threshold, ok := ruleThreshold(rule, categories)
+			if !ok {
+				continue
+			}
+			value, ok := ruleValue(rule, data)
+			if !ok {
+				continue
+			}
+
+			key := alertKey{rule.ID, station.ID}
+			alert, err := s.step(rule, station, open[key], threshold, value, data.Timestamp)
+			if err != nil {
+				log.Printf("Error evaluating alert rule %d for station %d: %v", rule.ID, station.ID, err)
+				continue
+			}
+			if alert == nil || alert.State == model.AlertResolved {
+				delete(open, key)
+			} else {
+				open[key] = alert
+			}
+		}
+	}
+}
+
+// alertStep is the outcome of one reading for the alert of a rule at a station
+type alertStep struct {
+	// alert is the alert after the reading, nil when there is none
+	alert       *model.Alert
+	transitions []model.AlertTransition
+	// changed tells whether alert has to be saved
+	changed bool
+	// discard tells that the pending alert recovered and is to be deleted
+	discard bool
+}
+
+// advanceAlert moves the alert of a rule at a station through pending, firing
+// and resolved with one reading. A breach opens a pending alert, which fires once
+// the breach has lasted the rule's duration and is discarded if the value recovers
+// before. A firing alert resolves when the value falls below the threshold by more
+// than the hysteresis. The given alert is not modified.
+func advanceAlert(rule *model.AlertRule, stationID uint, alert *model.Alert, threshold, value float64, timestamp time.Time) alertStep {
+	breach := value >= threshold
+	duration := time.Duration(rule.DurationMinutes) * time.Minute
+	var transitions []model.AlertTransition
+
+	switch {
+	case alert == nil:
+		if !breach {
+			return alertStep{}
+		}
+		alert = &model.Alert{
+			RuleID:    rule.ID,
+			StationID: stationID,
+			State:     model.AlertPending,
+			Threshold: threshold,
+			StartedAt: timestamp,
+		}
+		if duration > 0 {
+			transitions = append(transitions, model.AlertTransition{State: model.AlertPending, Value: value, Timestamp: timestamp})
+		}
+	case !timestamp.After(alert.LastReadingAt):
+		// Already evaluated, e.g. a reading overwritten with the same timestamp
+		return alertStep{alert: alert}
+	case !breach && alert.State == model.AlertPending:
+		// Recovered before the breach lasted long enough to fire
+		return alertStep{discard: true}
+	default:
+		next := *alert
+		alert = &next
+		if !breach && value < threshold-rule.Hysteresis {
+			now := timestamp
+			alert.State = model.AlertResolved
+			alert.ResolvedAt = &now
+			transitions = append(transitions, model.AlertTransition{State: model.AlertResolved, Value: value, Timestamp: timestamp})
+		}
+	}
+
+	alert.Value = value
+	alert.LastReadingAt = timestamp
+	if breach && value > alert.PeakValue {
+		alert.PeakValue = value
+	}
+	if alert.State == model.AlertPending && timestamp.Sub(alert.StartedAt) >= duration {
+		now := timestamp
+		alert.State = model.AlertFiring
+		alert.FiredAt = &now
+		transitions = append(transitions, model.AlertTransition{State: model.AlertFiring, Value: value, Timestamp: timestamp})
+	}
+	return alertStep{alert: alert, transitions: transitions, changed: true}
+}
+
+// step advances the alert of a rule at a station with one reading and stores the
+// outcome. It returns the alert after the reading, nil when there is none.
+func (s *AlertService) step(rule *model.AlertRule, station *model.Station, alert *model.Alert, threshold, value float64, timestamp time.Time) (*model.Alert, error) {
+	opened := alert == nil
+	next := advanceAlert(rule, station.ID, alert, threshold, value, timestamp)
+	if next.discard {
+		return nil, s.repo.DeleteAlert(alert.ID)
+	}
+	if !next.changed {
+		return next.alert, nil
+	}
+	alert, transitions := next.alert, next.transitions
+
+	var events []model.LiveEvent
+	err := s.repo.SaveAlert(alert, transitions, func(tx *gorm.DB) error {
+		events = transitionEvents(rule, station, alert, transitions)
+		return s.events.Stage(tx, events)
+	})
+	if err != nil {
+		if !opened || !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, err
+		}
+		// Another instance opened the alert first; the reading advances that one
+		existing, err := s.repo.GetOpenAlert(rule.ID, station.ID)
+		if err != nil {
+			return nil, err
+		}
+		return s.step(rule, station, existing, threshold, value, timestamp)
+	}
+	for _, event := range events {
+		s.events.Publish(event)
+	}
+	return alert, nil
+}
+
+// closeAlerts ends the open alerts of a disabled rule
+func (s *AlertService) closeAlerts(rule *model.AlertRule, now time.Time) {
+	alerts, err := s.repo.GetOpenAlerts([]uint{rule.ID})
+	if err != nil {
+		log.Printf("Error loading open alerts of rule %d: %v", rule.ID, err)
+		return
+	}
+
+	for i := range alerts {
+		alert := &alerts[i]
+		if alert.State == model.AlertPending {
+			if err := s.repo.DeleteAlert(alert.ID); err != nil {
+				log.Printf("Error discarding alert %d: %v", alert.ID, err)
+			}
+			continue
+		}
+
+		station, err := s.stationRepo.GetByID(alert.StationID)
+		if err != nil {
+			log.Printf("Error loading station of alert %d: %v", alert.ID, err)
+			continue
+		}
+
+		alert.State = model.AlertResolved
+		alert.ResolvedAt = &now
+		transitions := []model.AlertTransition{{State: model.AlertResolved, Value: alert.Value, Timestamp: now}}
+		var events []model.LiveEvent
+		err = s.repo.SaveAlert(alert, transitions, func(tx *gorm.DB) error {
+			events = transitionEvents(rule, station, alert, transitions)
+			return s.events.Stage(tx, events)
+		})
+		if err != nil {
+			log.Printf("Error resolving alert %d: %v", alert.ID, err)
+			continue
+		}
+		for _, event := range events {
+			s.events.Publish(event)
+		}
+	}
+}
+
+// transitionEvents returns the live events announcing the transitions of an alert
+// that fired or resolved
+func transitionEvents(rule *model.AlertRule, station *model.Station, alert *model.Alert, transitions []model.AlertTransition) []model.LiveEvent {
+	var events []model.LiveEvent
+	for _, transition := range transitions {
+		var eventType string
+		switch transition.State {
+		case model.AlertFiring:
+			eventType = model.LiveEventAlertFiring
+		case model.AlertResolved:
+			eventType = model.LiveEventAlertResolved
+		default:
+			continue
+		}
+
+		data := *alert
+		data.Rule = rule
+		events = append(events, model.LiveEvent{
+			Type:        eventType,
+			StationID:   station.ID,
+			StationCode: station.Code,
+			Province:    station.Province,
+			Timestamp:   alert.LastReadingAt,
+			Data:        data,
+		})
+	}
+	return events
+}
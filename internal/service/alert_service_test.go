@@ -0,0 +1,117 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+)
+
+func TestAdvanceAlert(t *testing.T) {
+	start := time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	rule := &model.AlertRule{ID: 1, DurationMinutes: 60, Hysteresis: 10}
+	instant := &model.AlertRule{ID: 2}
+
+	pending := &model.Alert{RuleID: 1, StationID: 7, State: model.AlertPending, Threshold: 100, Value: 120, PeakValue: 120, StartedAt: at(0), LastReadingAt: at(0)}
+	fired := at(60)
+	firing := &model.Alert{RuleID: 1, StationID: 7, State: model.AlertFiring, Threshold: 100, Value: 130, PeakValue: 150, StartedAt: at(0), FiredAt: &fired, LastReadingAt: at(60)}
+
+	tests := []struct {
+		name            string
+		rule            *model.AlertRule
+		alert           *model.Alert
+		value           float64
+		timestamp       time.Time
+		wantState       string // empty when no alert remains
+		wantTransitions []string
+		wantChanged     bool
+		wantDiscard     bool
+		wantPeak        float64
+	}{
+		{name: "no breach without an alert", rule: rule, value: 90, timestamp: at(0)},
+		{name: "breach opens a pending alert", rule: rule, value: 120, timestamp: at(0),
+			wantState: model.AlertPending, wantTransitions: []string{model.AlertPending}, wantChanged: true, wantPeak: 120},
+		{name: "breach fires at once without a duration", rule: instant, value: 100, timestamp: at(0),
+			wantState: model.AlertFiring, wantTransitions: []string{model.AlertFiring}, wantChanged: true, wantPeak: 100},
+		{name: "pending alert waits for the duration", rule: rule, alert: pending, value: 140, timestamp: at(30),
+			wantState: model.AlertPending, wantChanged: true, wantPeak: 140},
+		{name: "pending alert fires once the breach lasted the duration", rule: rule, alert: pending, value: 110, timestamp: at(60),
+			wantState: model.AlertFiring, wantTransitions: []string{model.AlertFiring}, wantChanged: true, wantPeak: 120},
+		{name: "pending alert recovering is discarded", rule: rule, alert: pending, value: 95, timestamp: at(30),
+			wantDiscard: true},
+		{name: "firing alert within the hysteresis stays firing", rule: rule, alert: firing, value: 92, timestamp: at(120),
+			wantState: model.AlertFiring, wantChanged: true, wantPeak: 150},
+		{name: "firing alert exactly at the hysteresis stays firing", rule: rule, alert: firing, value: 90, timestamp: at(120),
+			wantState: model.AlertFiring, wantChanged: true, wantPeak: 150},
+		{name: "firing alert below the hysteresis resolves", rule: rule, alert: firing, value: 89, timestamp: at(120),
+			wantState: model.AlertResolved, wantTransitions: []string{model.AlertResolved}, wantChanged: true, wantPeak: 150},
+		{name: "reading already evaluated is ignored", rule: rule, alert: firing, value: 50, timestamp: at(60),
+			wantState: model.AlertFiring, wantPeak: 150},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before model.Alert
+			if tt.alert != nil {
+				before = *tt.alert
+			}
+
+			step := advanceAlert(tt.rule, 7, tt.alert, 100, tt.value, tt.timestamp)
+
+			if step.discard != tt.wantDiscard || step.changed != tt.wantChanged {
+				t.Errorf("discard = %v, changed = %v; want %v, %v", step.discard, step.changed, tt.wantDiscard, tt.wantChanged)
+			}
+			if tt.wantState == "" {
+				if step.alert != nil {
+					t.Errorf("alert in state %s, want none", step.alert.State)
+				}
+			} else {
+				if step.alert == nil {
+					t.Fatalf("no alert, want state %s", tt.wantState)
+				}
+				if step.alert.State != tt.wantState || step.alert.PeakValue != tt.wantPeak {
+					t.Errorf("state %s with peak %v, want %s with peak %v", step.alert.State, step.alert.PeakValue, tt.wantState, tt.wantPeak)
+				}
+			}
+
+			var states []string
+			for _, transition := range step.transitions {
+				states = append(states, transition.State)
+			}
+			if len(states) != len(tt.wantTransitions) {
+				t.Fatalf("transitions = %v, want %v", states, tt.wantTransitions)
+			}
+			for i := range states {
+				if states[i] != tt.wantTransitions[i] {
+					t.Errorf("transitions = %v, want %v", states, tt.wantTransitions)
+				}
+			}
+
+			if tt.alert != nil && (tt.alert.State != before.State || tt.alert.Value != before.Value || !tt.alert.LastReadingAt.Equal(before.LastReadingAt)) {
+				t.Error("advanceAlert modified the given alert")
+			}
+		})
+	}
+}
+
+func TestValidateRuleRejectsNegativeSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		rule model.AlertRule
+		want error
+	}{
+		{"negative duration", model.AlertRule{Parameter: model.ParameterISPU, Threshold: floatPtr(100), DurationMinutes: -5}, ErrRuleDuration},
+		{"negative hysteresis", model.AlertRule{Parameter: model.ParameterISPU, Threshold: floatPtr(100), Hysteresis: -1}, ErrRuleHysteresis},
+		{"zero duration and hysteresis", model.AlertRule{Parameter: model.ParameterISPU, Threshold: floatPtr(100)}, nil},
+	}
+
+	s := &AlertService{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.validateRule(&tt.rule); err != tt.want {
+				t.Errorf("validateRule = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+const (
+	// flatlineMinReadings is the number of identical consecutive values that marks a stuck sensor
+	flatlineMinReadings = 4
+	// spikeFactor is how many times a value must exceed both neighbours to count as a spike
+	spikeFactor = 5.0
+	// spikeMinDelta (µg/m³) keeps noise around very low concentrations from counting as spikes
+	spikeMinDelta = 30.0
+	// outOfBandMinReadings is the number of readings needed before the statistical band is trusted
+	outOfBandMinReadings = 24
+	// outOfBandSigma is the distance from the mean, in standard deviations, that counts as out of band
+	outOfBandSigma = 4.0
+)
+
+// flatlineFloors (µg/m³) are the resolution floors of the analyzers. Clean air
+// reads a steady value at or below the floor, such as 0 or a CO concentration
+// reported in whole mg/m³, so it does not mark a stuck sensor.
+var flatlineFloors = map[model.Pollutant]float64{
+	model.PollutantPM25: 1,
+	model.PollutantPM10: 1,
+	model.PollutantCO:   1000,
+	model.PollutantNO2:  2,
+	model.PollutantO3:   2,
+	model.PollutantSO2:  2,
+	model.PollutantHC:   1,
+}
+
+// AnomalyDetectionService periodically scans recent readings for broken-analyzer
+// patterns, marks the affected measurements as suspect and records quality events
+type AnomalyDetectionService struct {
+	airQualityRepo *repository.AirQualityRepository
+	eventRepo      *repository.QualityEventRepository
+	interval       time.Duration
+	window         time.Duration
+}
+
+func NewAnomalyDetectionService(
+	airQualityRepo *repository.AirQualityRepository,
+	eventRepo *repository.QualityEventRepository,
+	interval time.Duration,
+	window time.Duration,
+) *AnomalyDetectionService {
+	return &AnomalyDetectionService{
+		airQualityRepo: airQualityRepo,
+		eventRepo:      eventRepo,
+		interval:       interval,
+		window:         window,
+	}
+}
+
+// Run scans immediately and then on every interval until ctx is cancelled
+func (s *AnomalyDetectionService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if count, err := s.Scan(); err != nil {
+			log.Printf("Error scanning readings for anomalies: %v", err)
+		} else if count > 0 {
+			log.Printf("Anomaly scan recorded %d new quality events", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *AnomalyDetectionService) GetEvents(filter model.QualityEventFilter) ([]model.QualityEvent, error) {
+	return s.eventRepo.List(filter)
+}
+
+// AcknowledgeEvent marks an event as reviewed by an operator
+func (s *AnomalyDetectionService) AcknowledgeEvent(id uint) (*model.QualityEvent, error) {
+	if _, err := s.eventRepo.GetByID(id); err != nil {
+		return nil, err
+	}
+	if err := s.eventRepo.Acknowledge(id); err != nil {
+		return nil, err
+	}
+	return s.eventRepo.GetByID(id)
+}
+
+// seriesPoint is one usable measurement of a pollutant
+type seriesPoint struct {
+	reading *model.AirQuality
+	value   float64
+}
+
+// detection is an anomaly found in a pollutant series
+type detection struct {
+	eventType string
+	pollutant model.Pollutant
+	points    []seriesPoint
+	details   string
+}
+
+// Scan inspects readings within the scan window and returns the number of new
+// quality events. Only one instance scans at a time; the others return 0.
+func (s *AnomalyDetectionService) Scan() (int, error) {
+	newEvents := 0
+	_, err := s.eventRepo.WithScanLock(func() error {
+		var err error
+		newEvents, err = s.scan()
+		return err
+	})
+	return newEvents, err
+}
+
+func (s *AnomalyDetectionService) scan() (int, error) {
+	readings, err := s.airQualityRepo.GetSince(time.Now().Add(-s.window))
+	if err != nil {
+		return 0, err
+	}
+
+	var detections []detection
+	for start := 0; start < len(readings); {
+		end := start
+		for end < len(readings) && readings[end].StationID == readings[start].StationID {
+			end++
+		}
+		detections = append(detections, detectStationAnomalies(readings[start:end])...)
+		start = end
+	}
+
+	changed := make(map[uint]*model.AirQuality)
+	newEvents := 0
+	for _, d := range detections {
+		for _, point := range d.points {
+			if point.reading.QCFlags.Get(d.pollutant) == model.QCValid {
+				point.reading.QCFlags.Set(d.pollutant, model.QCSuspect)
+				point.reading.QCStatus = point.reading.QCFlags.Worst()
+				changed[point.reading.ID] = point.reading
+			}
+		}
+
+		first, last := d.points[0], d.points[len(d.points)-1]
+		value := first.value
+		event := &model.QualityEvent{
+			StationID:    first.reading.StationID,
+			Pollutant:    d.pollutant,
+			Type:         d.eventType,
+			StartTime:    first.reading.Timestamp,
+			EndTime:      last.reading.Timestamp,
+			ReadingCount: len(d.points),
+			Value:        &value,
+			Details:      d.details,
+		}
+		isNew, err := s.record(event, d.points)
+		if err != nil {
+			return newEvents, err
+		}
+		if isNew {
+			newEvents++
+			log.Printf("Quality event at station %d: %s on %s (%s)", event.StationID, event.Type, event.Pollutant, event.Details)
+		}
+	}
+
+	for id, reading := range changed {
+		if err := s.airQualityRepo.UpdateQCFlags(id, reading.QCFlags, reading.QCStatus); err != nil {
+			return newEvents, err
+		}
+	}
+
+	return newEvents, nil
+}
+
+// record stores a detection and returns true when it is a new event. A detection
+// overlapping an event found by a previous scan extends that event instead, so a
+// flatline that keeps growing or slides out of the scan window stays one event.
+func (s *AnomalyDetectionService) record(event *model.QualityEvent, points []seriesPoint) (bool, error) {
+	existing, err := s.eventRepo.FindOverlapping(event)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, s.eventRepo.Save(event)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Readings within the existing range were counted by the earlier scan
+	added := 0
+	for _, point := range points {
+		if point.reading.Timestamp.Before(existing.StartTime) || point.reading.Timestamp.After(existing.EndTime) {
+			added++
+		}
+	}
+
+	event.ID = existing.ID
+	event.ReadingCount = existing.ReadingCount + added
+	if existing.StartTime.Before(event.StartTime) {
+		event.StartTime = existing.StartTime
+	}
+	if existing.EndTime.After(event.EndTime) {
+		event.EndTime = existing.EndTime
+	}
+	if event.Type == model.QualityEventFlatline {
+		event.Details = flatlineDetails(*event.Value, event.ReadingCount)
+	}
+	return false, s.eventRepo.Save(event)
+}
+
+// detectStationAnomalies runs every detector over each pollutant of one station's
+// readings, which must be ordered by time
+func detectStationAnomalies(readings []model.AirQuality) []detection {
+	var detections []detection
+
+	for _, pollutant := range model.Pollutants {
+		points := make([]seriesPoint, 0, len(readings))
+		for i := range readings {
+			concentration := readings[i].Concentration(pollutant)
+			if concentration == nil || readings[i].QCFlags.Get(pollutant) == model.QCInvalid {
+				continue
+			}
+			points = append(points, seriesPoint{reading: &readings[i], value: *concentration})
+		}
+
+		spikes := detectSpikes(pollutant, points)
+		detections = append(detections, detectFlatlines(pollutant, points)...)
+		detections = append(detections, spikes...)
+		detections = append(detections, detectOutOfBand(pollutant, points, spikes)...)
+	}
+
+	return detections
+}
+
+// detectFlatlines finds runs of identical consecutive values above the resolution
+// floor of the pollutant
+func detectFlatlines(pollutant model.Pollutant, points []seriesPoint) []detection {
+	var detections []detection
+
+	for start := 0; start < len(points); {
+		end := start + 1
+		for end < len(points) && points[end].value == points[start].value {
+			end++
+		}
+		if end-start >= flatlineMinReadings && points[start].value > flatlineFloors[pollutant] {
+			detections = append(detections, detection{
+				eventType: model.QualityEventFlatline,
+				pollutant: pollutant,
+				points:    points[start:end],
+				details:   flatlineDetails(points[start].value, end-start),
+			})
+		}
+		start = end
+	}
+
+	return detections
+}
+
+func flatlineDetails(value float64, count int) string {
+	return fmt.Sprintf("value %.2f repeated %d times", value, count)
+}
+
+// detectSpikes finds single values far above both of their neighbours
+func detectSpikes(pollutant model.Pollutant, points []seriesPoint) []detection {
+	var detections []detection
+
+	for i := 1; i < len(points)-1; i++ {
+		base := math.Max(points[i-1].value, points[i+1].value)
+		value := points[i].value
+		if value > base*spikeFactor && value-base > spikeMinDelta {
+			detections = append(detections, detection{
+				eventType: model.QualityEventSpike,
+				pollutant: pollutant,
+				points:    points[i : i+1],
+				details:   fmt.Sprintf("value %.2f against neighbours around %.2f", value, base),
+			})
+		}
+	}
+
+	return detections
+}
+
+// detectOutOfBand finds values outside the plausible range or far outside the
+// statistical band of the series. Readings already reported as spikes are skipped.
+func detectOutOfBand(pollutant model.Pollutant, points []seriesPoint, spikes []detection) []detection {
+	var detections []detection
+
+	reported := make(map[uint]bool, len(spikes))
+	for _, spike := range spikes {
+		reported[spike.points[0].reading.ID] = true
+	}
+
+	mean, stddev := 0.0, 0.0
+	if len(points) >= outOfBandMinReadings {
+		for _, point := range points {
+			mean += point.value
+		}
+		mean /= float64(len(points))
+		for _, point := range points {
+			stddev += (point.value - mean) * (point.value - mean)
+		}
+		stddev = math.Sqrt(stddev / float64(len(points)))
+	}
+
+	for i, point := range points {
+		if reported[point.reading.ID] {
+			continue
+		}
+
+		details := ""
+		if checkRange(pollutant, point.value) != model.QCValid {
+			details = fmt.Sprintf("value %.2f outside plausible range", point.value)
+		} else if stddev > 0 && math.Abs(point.value-mean) > outOfBandSigma*stddev {
+			details = fmt.Sprintf("value %.2f outside band %.2f ± %.0fσ (σ=%.2f)", point.value, mean, outOfBandSigma, stddev)
+		}
+
+		if details != "" {
+			detections = append(detections, detection{
+				eventType: model.QualityEventOutOfBand,
+				pollutant: pollutant,
+				points:    points[i : i+1],
+				details:   details,
+			})
+		}
+	}
+
+	return detections
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+)
+
+// series builds points whose readings are numbered from 1 in order
+func series(values ...float64) []seriesPoint {
+	points := make([]seriesPoint, len(values))
+	for i, value := range values {
+		points[i] = seriesPoint{reading: &model.AirQuality{ID: uint(i + 1)}, value: value}
+	}
+	return points
+}
+
+// repeat returns count copies of value
+func repeat(value float64, count int) []float64 {
+	values := make([]float64, count)
+	for i := range values {
+		values[i] = value
+	}
+	return values
+}
+
+// detectedIDs returns the reading IDs of each detection
+func detectedIDs(detections []detection) [][]uint {
+	ids := make([][]uint, 0, len(detections))
+	for _, d := range detections {
+		var readings []uint
+		for _, point := range d.points {
+			readings = append(readings, point.reading.ID)
+		}
+		ids = append(ids, readings)
+	}
+	return ids
+}
+
+func TestDetectFlatlines(t *testing.T) {
+	tests := []struct {
+		name      string
+		pollutant model.Pollutant
+		values    []float64
+		want      [][]uint
+	}{
+		{"stuck sensor", model.PollutantPM25, []float64{30, 42, 42, 42, 42, 35}, [][]uint{{2, 3, 4, 5}}},
+		{"run too short", model.PollutantPM25, []float64{42, 42, 42, 35}, [][]uint{}},
+		{"varying values", model.PollutantPM25, []float64{30, 31, 32, 33, 34}, [][]uint{}},
+		{"two runs", model.PollutantPM10, []float64{50, 50, 50, 50, 60, 60, 60, 60}, [][]uint{{1, 2, 3, 4}, {5, 6, 7, 8}}},
+		{"steady zero", model.PollutantSO2, repeat(0, 6), [][]uint{}},
+		{"at resolution floor", model.PollutantO3, repeat(2, 6), [][]uint{}},
+		{"coarse CO", model.PollutantCO, repeat(1000, 6), [][]uint{}},
+		{"CO above floor", model.PollutantCO, repeat(3000, 4), [][]uint{{1, 2, 3, 4}}},
+		{"empty series", model.PollutantPM25, nil, [][]uint{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detections := detectFlatlines(tt.pollutant, series(tt.values...))
+			if got := detectedIDs(detections); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("detectFlatlines() = %v, want %v", got, tt.want)
+			}
+			for _, d := range detections {
+				if d.eventType != model.QualityEventFlatline || d.pollutant != tt.pollutant {
+					t.Errorf("detection = %s on %s, want %s on %s", d.eventType, d.pollutant, model.QualityEventFlatline, tt.pollutant)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectSpikes(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   [][]uint
+	}{
+		{"spike", []float64{20, 25, 400, 22, 20}, [][]uint{{3}}},
+		{"below factor", []float64{40, 150, 45}, [][]uint{}},
+		{"below minimum delta", []float64{1, 20, 2}, [][]uint{}},
+		{"edges are not spikes", []float64{400, 20, 20, 400}, [][]uint{}},
+		{"too few points", []float64{20, 400}, [][]uint{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detections := detectSpikes(model.PollutantPM25, series(tt.values...))
+			if got := detectedIDs(detections); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("detectSpikes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectOutOfBand(t *testing.T) {
+	steady := append(repeat(20, outOfBandMinReadings-1), 200, 20)
+	short := []float64{20, 200, 20}
+
+	tests := []struct {
+		name       string
+		values     []float64
+		skipSpikes bool
+		want       [][]uint
+	}{
+		{"outside plausible range", []float64{20, 1200, 25}, false, [][]uint{{2}}},
+		{"negative value", []float64{20, -5, 25}, false, [][]uint{{2}}},
+		{"outside statistical band", steady, false, [][]uint{{uint(outOfBandMinReadings)}}},
+		{"band needs enough readings", short, false, [][]uint{}},
+		{"spikes are skipped", steady, true, [][]uint{}},
+		{"flat series has no band", repeat(20, outOfBandMinReadings), false, [][]uint{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points := series(tt.values...)
+			var spikes []detection
+			if tt.skipSpikes {
+				spikes = detectSpikes(model.PollutantPM25, points)
+			}
+			detections := detectOutOfBand(model.PollutantPM25, points, spikes)
+			if got := detectedIDs(detections); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("detectOutOfBand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
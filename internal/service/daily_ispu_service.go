@@ -0,0 +1,260 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/repository"
+)
+
+// averagingHours is the averaging period of each pollutant in the daily ISPU
+// (Permen LHK No. 14 Tahun 2020)
+var averagingHours = map[model.Pollutant]int{
+	model.PollutantPM10: 24,
+	model.PollutantPM25: 24,
+	model.PollutantSO2:  24,
+	model.PollutantCO:   8,
+	model.PollutantO3:   1,
+	model.PollutantNO2:  1,
+	model.PollutantHC:   3,
+}
+
+// minCompleteness is the share of hourly values an averaging window needs to be valid
+const minCompleteness = 0.75
+
+// DailyISPUService computes regulation-conformant daily ISPU values from the
+// hourly rollup
+type DailyISPUService struct {
+	rollupRepo   *repository.RollupRepository
+	stationRepo  *repository.StationRepository
+	categoryRepo *repository.CategoryRepository
+}
+
+func NewDailyISPUService(
+	rollupRepo *repository.RollupRepository,
+	stationRepo *repository.StationRepository,
+	categoryRepo *repository.CategoryRepository,
+) *DailyISPUService {
+	return &DailyISPUService{
+		rollupRepo:   rollupRepo,
+		stationRepo:  stationRepo,
+		categoryRepo: categoryRepo,
+	}
+}
+
+// GetStationDailyISPU computes the daily ISPU of a station for a calendar date,
+// taken as a day in the station's time zone
+func (s *DailyISPUService) GetStationDailyISPU(stationID uint, date time.Time) (*model.DailyISPU, error) {
+	station, err := s.stationRepo.GetByID(stationID)
+	if err != nil {
+		return nil, err
+	}
+
+	dayStart := model.LocalDay(date, station.Location())
+	from, to := dailyWindow(dayStart)
+	hourly, err := s.rollupRepo.List(model.PeriodHour, stationID, "", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, _ := s.categoryRepo.GetAll()
+	daily := computeDailyISPU(hourly, dayStart, categories)
+	fillStation(daily, station)
+	return daily, nil
+}
+
+// GetNationalSummary computes the daily ISPU of every active station for a calendar
+// date, each station using its own local day
+func (s *DailyISPUService) GetNationalSummary(date time.Time) (*model.DailyISPUSummary, error) {
+	stations, err := s.stationRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// One query covers the local days of every time zone
+	dayStarts := make([]time.Time, len(stations))
+	var from, to time.Time
+	for i := range stations {
+		dayStarts[i] = model.LocalDay(date, stations[i].Location())
+		start, end := dailyWindow(dayStarts[i])
+		if from.IsZero() || start.Before(from) {
+			from = start
+		}
+		if end.After(to) {
+			to = end
+		}
+	}
+
+	hourly, err := s.rollupRepo.ListAll(model.PeriodHour, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	byStation := make(map[uint][]model.AirQualityAggregate)
+	for _, aggregate := range hourly {
+		byStation[aggregate.StationID] = append(byStation[aggregate.StationID], aggregate)
+	}
+
+	categories, _ := s.categoryRepo.GetAll()
+	summary := &model.DailyISPUSummary{
+		Date:                 date.Format("2006-01-02"),
+		StationCount:         len(stations),
+		CategoryDistribution: make(map[string]int),
+		Stations:             make([]model.DailyISPU, 0, len(stations)),
+	}
+
+	total := 0
+	for i := range stations {
+		daily := computeDailyISPU(byStation[stations[i].ID], dayStarts[i], categories)
+		fillStation(daily, &stations[i])
+		summary.Stations = append(summary.Stations, *daily)
+
+		if daily.ISPU != nil {
+			summary.ReportingStations++
+			summary.CategoryDistribution[daily.Category]++
+			total += *daily.ISPU
+		}
+	}
+	if summary.ReportingStations > 0 {
+		summary.AverageISPU = float64(total) / float64(summary.ReportingStations)
+	}
+
+	// Worst stations first, stations without a daily value last
+	sort.SliceStable(summary.Stations, func(i, j int) bool {
+		a, b := summary.Stations[i].ISPU, summary.Stations[j].ISPU
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return *a > *b
+	})
+
+	return summary, nil
+}
+
+// dailyWindow returns the hourly data range needed for the day starting at dayStart,
+// including the hours before midnight used by rolling windows ending early in the day
+func dailyWindow(dayStart time.Time) (time.Time, time.Time) {
+	longest := 0
+	for _, hours := range averagingHours {
+		if hours > longest {
+			longest = hours
+		}
+	}
+	return dayStart.Add(-time.Duration(longest-1) * time.Hour), dayStart.AddDate(0, 0, 1)
+}
+
+// computeDailyISPU applies each pollutant's averaging period to the hourly means of
+// the local day starting at dayStart and takes the highest complete sub-index as
+// the daily ISPU
+func computeDailyISPU(hourly []model.AirQualityAggregate, dayStart time.Time, categories []model.ISPUCategory) *model.DailyISPU {
+	series := make(map[model.Pollutant]map[time.Time]float64)
+	for _, aggregate := range hourly {
+		if series[aggregate.Pollutant] == nil {
+			series[aggregate.Pollutant] = make(map[time.Time]float64)
+		}
+		series[aggregate.Pollutant][aggregate.BucketStart.UTC()] = aggregate.Mean
+	}
+
+	daily := &model.DailyISPU{
+		Date:       dayStart.Format("2006-01-02"),
+		Status:     model.DailyStatusInsufficientData,
+		Pollutants: make([]model.DailyPollutantIndex, 0, len(model.Pollutants)),
+	}
+
+	reported, complete := 0, 0
+	for _, pollutant := range model.Pollutants {
+		values, ok := series[pollutant]
+		if !ok {
+			continue
+		}
+		reported++
+
+		index := dailyPollutantIndex(pollutant, values, dayStart.UTC())
+		daily.Pollutants = append(daily.Pollutants, index)
+		if !index.Complete {
+			continue
+		}
+		complete++
+
+		if daily.ISPU == nil || *index.SubIndex > *daily.ISPU {
+			daily.ISPU = index.SubIndex
+			daily.CriticalPollutant = pollutant
+		}
+	}
+
+	switch {
+	case complete > 0 && complete == reported:
+		daily.Status = model.DailyStatusComplete
+	case complete > 0:
+		daily.Status = model.DailyStatusPartial
+	}
+
+	if daily.ISPU != nil {
+		daily.Category, daily.Color = categorize(*daily.ISPU, categories)
+	}
+	return daily
+}
+
+// dailyPollutantIndex computes the daily concentration of a pollutant: the mean of
+// the day for 24-hour pollutants, otherwise the highest rolling-window mean
+// ending within the day. Both the day and each window need 75% of their hours.
+func dailyPollutantIndex(pollutant model.Pollutant, values map[time.Time]float64, dayStart time.Time) model.DailyPollutantIndex {
+	window := averagingHours[pollutant]
+	index := model.DailyPollutantIndex{
+		Pollutant:       pollutant,
+		AveragingPeriod: fmt.Sprintf("%dh", window),
+	}
+
+	for h := 0; h < 24; h++ {
+		if _, ok := values[dayStart.Add(time.Duration(h)*time.Hour)]; ok {
+			index.HoursAvailable++
+		}
+	}
+	if float64(index.HoursAvailable) < minCompleteness*24 {
+		return index
+	}
+
+	required := int(math.Ceil(minCompleteness * float64(window)))
+	var best *float64
+	for end := 0; end < 24; end++ {
+		// Windows of 24 hours only make sense for the whole day
+		if window == 24 && end != 23 {
+			continue
+		}
+
+		sum, count := 0.0, 0
+		for h := end - window + 1; h <= end; h++ {
+			if value, ok := values[dayStart.Add(time.Duration(h)*time.Hour)]; ok {
+				sum += value
+				count++
+			}
+		}
+		if count < required {
+			continue
+		}
+
+		mean := sum / float64(count)
+		if best == nil || mean > *best {
+			best = &mean
+		}
+	}
+	if best == nil {
+		return index
+	}
+
+	subIndex, _ := CalculateSubIndex(pollutant, *best)
+	index.Concentration = best
+	index.SubIndex = &subIndex
+	index.Complete = true
+	return index
+}
+
+func fillStation(daily *model.DailyISPU, station *model.Station) {
+	daily.StationID = station.ID
+	daily.StationCode = station.Code
+	daily.StationName = station.Name
+	daily.Province = station.Province
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+)
+
+// hourSpan sets the hourly mean of the hours [from, to) counted from local midnight
+type hourSpan struct {
+	from, to int
+	value    float64
+}
+
+func hourlyValues(dayStart time.Time, spans ...hourSpan) map[time.Time]float64 {
+	values := make(map[time.Time]float64)
+	for _, span := range spans {
+		for h := span.from; h < span.to; h++ {
+			values[dayStart.Add(time.Duration(h)*time.Hour)] = span.value
+		}
+	}
+	return values
+}
+
+func TestDailyPollutantIndex(t *testing.T) {
+	dayStart := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name              string
+		pollutant         model.Pollutant
+		spans             []hourSpan
+		wantHours         int
+		wantConcentration float64
+		wantComplete      bool
+	}{
+		{
+			name:              "exactly 75% of the day",
+			pollutant:         model.PollutantPM10,
+			spans:             []hourSpan{{0, 18, 100}},
+			wantHours:         18,
+			wantConcentration: 100,
+			wantComplete:      true,
+		},
+		{
+			name:      "just below 75% of the day",
+			pollutant: model.PollutantPM10,
+			spans:     []hourSpan{{0, 17, 100}},
+			wantHours: 17,
+		},
+		{
+			name:      "hours of the previous day do not count towards the day",
+			pollutant: model.PollutantO3,
+			spans:     []hourSpan{{-10, 0, 50}, {0, 17, 50}},
+			wantHours: 17,
+		},
+		{
+			name:              "24-hour mean ignores the previous day",
+			pollutant:         model.PollutantPM10,
+			spans:             []hourSpan{{-6, 0, 500}, {0, 24, 100}},
+			wantHours:         24,
+			wantConcentration: 100,
+			wantComplete:      true,
+		},
+		{
+			name:              "8-hour window reaching into the previous day",
+			pollutant:         model.PollutantCO,
+			spans:             []hourSpan{{-7, 0, 9000}, {0, 24, 1000}},
+			wantHours:         24,
+			wantConcentration: 8000,
+			wantComplete:      true,
+		},
+		{
+			name:              "8-hour windows with fewer than 6 hours are skipped",
+			pollutant:         model.PollutantCO,
+			spans:             []hourSpan{{0, 18, 1000}, {18, 20, 20000}},
+			wantHours:         20,
+			wantConcentration: 44000.0 / 6,
+			wantComplete:      true,
+		},
+		{
+			name:              "1-hour pollutant takes the highest hour",
+			pollutant:         model.PollutantO3,
+			spans:             []hourSpan{{0, 24, 50}, {13, 14, 200}},
+			wantHours:         24,
+			wantConcentration: 200,
+			wantComplete:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := dailyPollutantIndex(tt.pollutant, hourlyValues(dayStart, tt.spans...), dayStart)
+
+			if index.HoursAvailable != tt.wantHours {
+				t.Errorf("hours available = %d, want %d", index.HoursAvailable, tt.wantHours)
+			}
+			if index.Complete != tt.wantComplete {
+				t.Fatalf("complete = %v, want %v", index.Complete, tt.wantComplete)
+			}
+			if !tt.wantComplete {
+				if index.Concentration != nil || index.SubIndex != nil {
+					t.Errorf("incomplete index has concentration %v and sub-index %v", index.Concentration, index.SubIndex)
+				}
+				return
+			}
+
+			if math.Abs(*index.Concentration-tt.wantConcentration) > 1e-9 {
+				t.Errorf("concentration = %v, want %v", *index.Concentration, tt.wantConcentration)
+			}
+			if want, _ := CalculateSubIndex(tt.pollutant, tt.wantConcentration); *index.SubIndex != want {
+				t.Errorf("sub-index = %d, want %d", *index.SubIndex, want)
+			}
+		})
+	}
+}
+
+func TestComputeDailyISPULocalDay(t *testing.T) {
+	wit := time.FixedZone("WIT", 9*60*60)
+	date := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	// Hourly PM10 means over the WIT day of 10 March, which starts at 15:00 UTC on
+	// 9 March, and a high first hour of the next WIT day
+	witStart := model.LocalDay(date, wit)
+	var hourly []model.AirQualityAggregate
+	for h := 0; h <= 24; h++ {
+		mean := 100.0
+		if h == 24 {
+			mean = 1000
+		}
+		hourly = append(hourly, model.AirQualityAggregate{
+			Pollutant:   model.PollutantPM10,
+			BucketStart: witStart.Add(time.Duration(h) * time.Hour).UTC(),
+			Mean:        mean,
+		})
+	}
+
+	tests := []struct {
+		name       string
+		loc        *time.Location
+		wantISPU   *int
+		wantStatus string
+	}{
+		{"station in WIT", wit, intPtr(75), model.DailyStatusComplete},
+		{"station in UTC", time.UTC, nil, model.DailyStatusInsufficientData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			daily := computeDailyISPU(hourly, model.LocalDay(date, tt.loc), nil)
+
+			if daily.Date != "2024-03-10" {
+				t.Errorf("date = %s, want 2024-03-10", daily.Date)
+			}
+			if daily.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", daily.Status, tt.wantStatus)
+			}
+			switch {
+			case tt.wantISPU == nil && daily.ISPU != nil:
+				t.Errorf("ISPU = %d, want none", *daily.ISPU)
+			case tt.wantISPU != nil && (daily.ISPU == nil || *daily.ISPU != *tt.wantISPU):
+				t.Errorf("ISPU = %v, want %d", daily.ISPU, *tt.wantISPU)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -3,40 +3,47 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"math"
+	"sort"
 	"time"
 
 	"github.com/ispu-monitoring/backend/internal/model"
 	"github.com/ispu-monitoring/backend/internal/repository"
 	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
 type DashboardService struct {
-	stationRepo  *repository.StationRepository
+	stationRepo    *repository.StationRepository
 	airQualityRepo *repository.AirQualityRepository
-	categoryRepo *repository.CategoryRepository
-	redis        *redis.Client
+	categoryRepo   *repository.CategoryRepository
+	rollups        *RollupService
+	redis          *redis.Client
 }
 
 func NewDashboardService(
 	stationRepo *repository.StationRepository,
 	airQualityRepo *repository.AirQualityRepository,
 	categoryRepo *repository.CategoryRepository,
+	rollups *RollupService,
 	redis *redis.Client,
 ) *DashboardService {
 	return &DashboardService{
 		stationRepo:    stationRepo,
 		airQualityRepo: airQualityRepo,
 		categoryRepo:   categoryRepo,
+		rollups:        rollups,
 		redis:          redis,
 	}
 }
 
-func (s *DashboardService) GetOverview() (*model.DashboardOverview, error) {
+// GetOverview builds the dashboard summary. Only unfiltered results are cached.
+func (s *DashboardService) GetOverview(qc model.QCFilter) (*model.DashboardOverview, error) {
 	// Try cache first
-	if s.redis != nil {
+	if s.redis != nil && len(qc) == 0 {
 		cacheKey := "dashboard:overview"
 		ctx := context.Background()
-		
+
 		cached, err := s.redis.Get(ctx, cacheKey).Result()
 		if err == nil {
 			var overview model.DashboardOverview
@@ -45,54 +52,46 @@ func (s *DashboardService) GetOverview() (*model.DashboardOverview, error) {
 			}
 		}
 	}
-	
+
 	// Fetch data
 	totalStations, _ := s.stationRepo.CountAll()
 	activeStations, _ := s.stationRepo.CountActive()
-	averageISPU, _ := s.airQualityRepo.GetAverageISPU()
+	// The rollup answers the unfiltered average without scanning every reading
+	var averageISPU float64
+	if len(qc) == 0 {
+		averageISPU, _ = s.rollups.GetAverageISPU()
+	} else {
+		averageISPU, _ = s.airQualityRepo.GetAverageISPU(qc)
+	}
 	lastUpdate, _ := s.airQualityRepo.GetLatestTimestamp()
-	
+
 	// Get categories
 	categories, _ := s.categoryRepo.GetAll()
-	
+
 	// Get category distribution
-	distribution, _ := s.airQualityRepo.GetCategoryDistribution(categories)
-	
+	distribution, _ := s.airQualityRepo.GetCategoryDistribution(categories, qc)
+
 	// Get recent readings
-	latestData, _ := s.airQualityRepo.GetLatestForAllStations()
+	latestData, _ := s.airQualityRepo.GetLatestForAllStations(qc)
 	recentReadings := make([]model.StationWithAirQuality, 0)
-	
+
 	for _, data := range latestData {
-		if data.Station != nil {
-			category, _ := s.categoryRepo.GetCategoryForISPU(data.ISPU)
-			reading := model.StationWithAirQuality{
-				ID:         data.Station.ID,
-				Name:       data.Station.Name,
-				Code:       data.Station.Code,
-				Type:       data.Station.Type,
-				Latitude:   data.Station.Latitude,
-				Longitude:  data.Station.Longitude,
-				Province:   data.Station.Province,
-				City:       data.Station.City,
-				Address:    data.Station.Address,
-				ISPU:       data.ISPU,
-				PM25:       data.PM25,
-				PM10:       data.PM10,
-				CO:         data.CO,
-				NO2:        data.NO2,
-				O3:         data.O3,
-				SO2:        data.SO2,
-				Timestamp:  data.Timestamp,
-				LastUpdate: data.Timestamp,
-			}
-			if category != nil {
-				reading.Category = category.Category
-				reading.Color = category.Color
-			}
-			recentReadings = append(recentReadings, reading)
+		if data.Station != nil && data.ISPU != nil {
+			recentReadings = append(recentReadings, toStationWithAirQuality(data, categories))
 		}
 	}
-	
+
+	// Province statistics and data availability only cover active stations
+	var provinceStats []model.ProvinceStatistic
+	var availability model.DataAvailability
+	if stations, err := s.stationRepo.GetAll(); err == nil {
+		provinceStats = buildProvinceStatistics(stations, indexByStation(recentReadings))
+		availability = buildDataAvailability(stations)
+	} else {
+		provinceStats = []model.ProvinceStatistic{}
+		availability = buildDataAvailability(nil)
+	}
+
 	overview := &model.DashboardOverview{
 		Summary: model.DashboardSummary{
 			TotalStations:  totalStations,
@@ -102,29 +101,42 @@ func (s *DashboardService) GetOverview() (*model.DashboardOverview, error) {
 		},
 		CategoryDistribution: distribution,
 		RecentReadings:       recentReadings,
-		ProvinceStats:        []model.ProvinceStatistic{},
+		ProvinceStats:        provinceStats,
+		DataAvailability:     availability,
 	}
-	
+
 	// Cache for 3 minutes
-	if s.redis != nil {
+	if s.redis != nil && len(qc) == 0 {
 		ctx := context.Background()
 		data, _ := json.Marshal(overview)
 		s.redis.Set(ctx, "dashboard:overview", data, 3*time.Minute)
 	}
-	
+
 	return overview, nil
 }
 
+// GetDataAvailability counts the active stations by reporting status
+func (s *DashboardService) GetDataAvailability() (*model.DataAvailability, error) {
+	stations, err := s.stationRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	availability := buildDataAvailability(stations)
+	return &availability, nil
+}
+
 func (s *DashboardService) GetCategories() ([]model.ISPUCategory, error) {
 	return s.categoryRepo.GetAll()
 }
 
-func (s *DashboardService) GetMapStationsData() ([]model.StationWithAirQuality, error) {
+// GetMapStationsData returns every station with its latest reading. Only unfiltered
+// results are cached.
+func (s *DashboardService) GetMapStationsData(qc model.QCFilter) ([]model.StationWithAirQuality, error) {
 	// Try cache first
-	if s.redis != nil {
+	if s.redis != nil && len(qc) == 0 {
 		cacheKey := "map:stations"
 		ctx := context.Background()
-		
+
 		cached, err := s.redis.Get(ctx, cacheKey).Result()
 		if err == nil {
 			var stations []model.StationWithAirQuality
@@ -138,51 +150,20 @@ func (s *DashboardService) GetMapStationsData() ([]model.StationWithAirQuality,
 	categories, _ := s.categoryRepo.GetAll()
 
 	// Get latest data for all stations
-	latestData, err := s.airQualityRepo.GetLatestForAllStations()
+	latestData, err := s.airQualityRepo.GetLatestForAllStations(qc)
 	if err != nil {
 		return nil, err
 	}
 
 	mapStations := make([]model.StationWithAirQuality, 0)
 	for _, data := range latestData {
-		if data.Station != nil {
-			category := repository.GetCategoryForISPU(data.ISPU, categories)
-			stationData := model.StationWithAirQuality{
-				ID:        data.Station.ID,
-				Name:      data.Station.Name,
-				Code:      data.Station.Code,
-				Type:      data.Station.Type,
-				Latitude:  data.Station.Latitude,
-				Longitude: data.Station.Longitude,
-				Province:  data.Station.Province,
-				City:      data.Station.City,
-				Address:   data.Station.Address,
-				ISPU:      data.ISPU,
-				PM25:      data.PM25,
-				PM10:      data.PM10,
-				CO:        data.CO,
-				NO2:       data.NO2,
-				O3:        data.O3,
-				SO2:       data.SO2,
-				HC:        data.HC,
-				Category:  category,
-				Timestamp: data.Timestamp,
-			}
-			
-			// Find color for category
-			for _, cat := range categories {
-				if cat.Category == category {
-					stationData.Color = cat.Color
-					break
-				}
-			}
-			
-			mapStations = append(mapStations, stationData)
+		if data.Station != nil && data.ISPU != nil {
+			mapStations = append(mapStations, toStationWithAirQuality(data, categories))
 		}
 	}
 
 	// Cache for 1 minute
-	if s.redis != nil {
+	if s.redis != nil && len(qc) == 0 {
 		ctx := context.Background()
 		data, _ := json.Marshal(mapStations)
 		s.redis.Set(ctx, "map:stations", data, 1*time.Minute)
@@ -190,3 +171,249 @@ func (s *DashboardService) GetMapStationsData() ([]model.StationWithAirQuality,
 
 	return mapStations, nil
 }
+
+// GetStationSnapshot returns the latest data of the active stations among codes,
+// in the order given, and the codes matching no active station. Stations that
+// have not reported yet are known but have no data in the snapshot.
+func (s *DashboardService) GetStationSnapshot(codes []string) ([]model.StationWithAirQuality, []string, error) {
+	stations, err := s.stationRepo.GetAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	active := make(map[string]bool, len(stations))
+	for _, station := range stations {
+		active[station.Code] = true
+	}
+
+	readings, err := s.GetMapStationsData(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	byCode := make(map[string]model.StationWithAirQuality, len(readings))
+	for _, reading := range readings {
+		byCode[reading.Code] = reading
+	}
+
+	snapshot := make([]model.StationWithAirQuality, 0, len(codes))
+	var unknown []string
+	for _, code := range codes {
+		if !active[code] {
+			unknown = append(unknown, code)
+			continue
+		}
+		if reading, ok := byCode[code]; ok {
+			snapshot = append(snapshot, reading)
+		}
+	}
+	return snapshot, unknown, nil
+}
+
+// GetNearbyStations returns the active stations within radiusKm of a point, nearest
+// first, with their latest reading attached
+func (s *DashboardService) GetNearbyStations(lat, lon, radiusKm float64, limit int) ([]model.NearbyStation, error) {
+	stations, err := s.stationRepo.GetNearby(lat, lon, radiusKm, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	readings, err := s.GetMapStationsData(nil)
+	if err != nil {
+		return nil, err
+	}
+	byStation := indexByStation(readings)
+
+	for i := range stations {
+		reading, ok := byStation[stations[i].ID]
+		if !ok {
+			continue
+		}
+		ispu := reading.ISPU
+		lastUpdate := reading.Timestamp
+		stations[i].ISPU = &ispu
+		stations[i].Category = reading.Category
+		stations[i].Color = reading.Color
+		stations[i].CriticalPollutant = reading.CriticalPollutant
+		stations[i].LastUpdate = &lastUpdate
+	}
+	return stations, nil
+}
+
+// GetLatestByStation returns the latest map reading of every station keyed by station ID
+func (s *DashboardService) GetLatestByStation() (map[uint]*model.StationWithAirQuality, error) {
+	readings, err := s.GetMapStationsData(nil)
+	if err != nil {
+		return nil, err
+	}
+	return indexByStation(readings), nil
+}
+
+// GetMapStationsInBounds returns the map stations inside a bounding box, or every
+// station when bbox is nil
+func (s *DashboardService) GetMapStationsInBounds(qc model.QCFilter, bbox *model.BoundingBox) ([]model.StationWithAirQuality, error) {
+	stations, err := s.GetMapStationsData(qc)
+	if err != nil {
+		return nil, err
+	}
+	if bbox == nil {
+		return stations, nil
+	}
+	return filterBounds(stations, *bbox), nil
+}
+
+// GetMapClusters groups the map stations inside a bounding box into clusters for a
+// zoom level below ClusterMaxZoom
+func (s *DashboardService) GetMapClusters(qc model.QCFilter, bbox *model.BoundingBox, zoom int) (*model.MapClusters, error) {
+	stations, err := s.GetMapStationsInBounds(qc, bbox)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, _ := s.categoryRepo.GetAll()
+	return clusterStations(stations, zoom, categories), nil
+}
+
+// GetProvinceStatistics returns statistics for every province with active stations
+func (s *DashboardService) GetProvinceStatistics() ([]model.ProvinceStatistic, error) {
+	stations, err := s.stationRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	readings, err := s.GetMapStationsData(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildProvinceStatistics(stations, indexByStation(readings)), nil
+}
+
+// GetProvinceDetail drills a province down to its cities and active stations.
+// It returns gorm.ErrRecordNotFound when the province has no active station.
+func (s *DashboardService) GetProvinceDetail(province string) (*model.ProvinceDetail, error) {
+	stations, err := s.stationRepo.GetByProvince(province)
+	if err != nil {
+		return nil, err
+	}
+	if len(stations) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	readings, err := s.GetMapStationsData(nil)
+	if err != nil {
+		return nil, err
+	}
+	byStation := indexByStation(readings)
+
+	detail := &model.ProvinceDetail{
+		ProvinceStatistic: buildProvinceStatistic(stations[0].Province, stations, byStation),
+		Cities:            buildCityStatistics(stations, byStation),
+		Stations:          make([]model.StationWithAirQuality, 0, len(stations)),
+	}
+	for _, station := range stations {
+		if reading, ok := byStation[station.ID]; ok {
+			detail.Stations = append(detail.Stations, *reading)
+		}
+	}
+
+	return detail, nil
+}
+
+func indexByStation(readings []model.StationWithAirQuality) map[uint]*model.StationWithAirQuality {
+	byStation := make(map[uint]*model.StationWithAirQuality, len(readings))
+	for i := range readings {
+		byStation[readings[i].ID] = &readings[i]
+	}
+	return byStation
+}
+
+// buildDataAvailability counts stations by reporting status and lists the delayed
+// and offline ones, those silent the longest (or never heard from) first
+func buildDataAvailability(stations []model.Station) model.DataAvailability {
+	availability := model.DataAvailability{
+		TotalStations: len(stations),
+		Unavailable:   make([]model.StationAvailability, 0),
+	}
+	for _, station := range stations {
+		switch station.Status {
+		case model.StationStatusOnline:
+			availability.Online++
+			continue
+		case model.StationStatusDelayed:
+			availability.Delayed++
+		case model.StationStatusOffline:
+			availability.Offline++
+		default:
+			availability.Unknown++
+			continue
+		}
+		availability.Unavailable = append(availability.Unavailable, model.StationAvailability{
+			ID:                station.ID,
+			Code:              station.Code,
+			Name:              station.Name,
+			Province:          station.Province,
+			Status:            station.Status,
+			ReportingInterval: station.ReportingInterval,
+			LastSeenAt:        station.LastSeenAt,
+			StatusChangedAt:   station.StatusChangedAt,
+		})
+	}
+	if len(stations) > 0 {
+		availability.OnlinePercentage = math.Round(float64(availability.Online)/float64(len(stations))*1000) / 10
+	}
+
+	sort.SliceStable(availability.Unavailable, func(i, j int) bool {
+		a, b := availability.Unavailable[i].LastSeenAt, availability.Unavailable[j].LastSeenAt
+		if a == nil || b == nil {
+			return a == nil && b != nil
+		}
+		return a.Before(*b)
+	})
+	return availability
+}
+
+// toStationWithAirQuality combines a reading with its station and category colouring.
+// The reading must have an ISPU.
+func toStationWithAirQuality(data model.AirQuality, categories []model.ISPUCategory) model.StationWithAirQuality {
+	category, color := categorize(*data.ISPU, categories)
+	stationData := model.StationWithAirQuality{
+		ID:                data.Station.ID,
+		Name:              data.Station.Name,
+		Code:              data.Station.Code,
+		Type:              data.Station.Type,
+		Latitude:          data.Station.Latitude,
+		Longitude:         data.Station.Longitude,
+		Province:          data.Station.Province,
+		City:              data.Station.City,
+		Address:           data.Station.Address,
+		ISPU:              *data.ISPU,
+		PM25:              data.PM25,
+		PM10:              data.PM10,
+		CO:                data.CO,
+		NO2:               data.NO2,
+		O3:                data.O3,
+		SO2:               data.SO2,
+		HC:                data.HC,
+		Category:          category,
+		Color:             color,
+		Timestamp:         data.Timestamp,
+		LastUpdate:        data.Timestamp,
+		SubIndices:        data.SubIndices,
+		CriticalPollutant: data.CriticalPollutant,
+		Status:            data.Station.Status,
+	}
+
+	return stationData
+}
+
+// categorize returns the category name and color of an ISPU value
+func categorize(ispu int, categories []model.ISPUCategory) (string, string) {
+	category := repository.GetCategoryForISPU(ispu, categories)
+
+	// Find color for category
+	for _, cat := range categories {
+		if cat.Category == category {
+			return category, cat.Color
+		}
+	}
+	return category, ""
+}
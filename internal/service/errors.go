@@ -0,0 +1,69 @@
+package service
+
+// IngestError describes why an incoming reading was rejected before being stored
+type IngestError struct {
+	Code    string
+	Message string
+}
+
+func (e *IngestError) Error() string {
+	return e.Message
+}
+
+var (
+	ErrNoPollutants     = &IngestError{Code: "NO_POLLUTANTS", Message: "At least one pollutant concentration is required to compute ISPU"}
+	ErrDuplicateReading = &IngestError{Code: "DUPLICATE_READING", Message: "A reading for this station and timestamp already exists"}
+	ErrStationRequired  = &IngestError{Code: "STATION_REQUIRED", Message: "Either station_code or station_id is required"}
+	ErrStationNotFound  = &IngestError{Code: "STATION_NOT_FOUND", Message: "Station does not exist"}
+	ErrStationInactive  = &IngestError{Code: "STATION_INACTIVE", Message: "Station is deactivated and does not accept readings"}
+	ErrStationMismatch  = &IngestError{Code: "STATION_MISMATCH", Message: "station_code and station_id refer to different stations"}
+	ErrFutureTimestamp  = &IngestError{Code: "FUTURE_TIMESTAMP", Message: "Reading timestamp is in the future"}
+)
+
+// RuleError describes why an alert rule was rejected
+type RuleError struct {
+	Code    string
+	Message string
+}
+
+func (e *RuleError) Error() string {
+	return e.Message
+}
+
+var (
+	ErrRuleParameter  = &RuleError{Code: "INVALID_PARAMETER", Message: "Parameter must be ispu or one of pm25, pm10, co, no2, o3, so2 and hc"}
+	ErrRuleThreshold  = &RuleError{Code: "INVALID_THRESHOLD", Message: "Exactly one of threshold and category is required"}
+	ErrRuleCategory   = &RuleError{Code: "INVALID_CATEGORY", Message: "Category must be the name of an ISPU category"}
+	ErrRuleScope      = &RuleError{Code: "INVALID_SCOPE", Message: "A rule is scoped to either station_id or province, not both"}
+	ErrRuleStation    = &RuleError{Code: "STATION_NOT_FOUND", Message: "Station does not exist"}
+	ErrRuleDuration   = &RuleError{Code: "INVALID_DURATION", Message: "duration_minutes must not be negative"}
+	ErrRuleHysteresis = &RuleError{Code: "INVALID_HYSTERESIS", Message: "hysteresis must not be negative"}
+)
+
+// WebhookError describes why a webhook subscription was rejected
+type WebhookError struct {
+	Code    string
+	Message string
+}
+
+func (e *WebhookError) Error() string {
+	return e.Message
+}
+
+var (
+	ErrWebhookEventType = &WebhookError{Code: "INVALID_EVENT_TYPE", Message: "event_types may only contain reading, category_change, alert_firing, alert_resolved and station_status"}
+	ErrWebhookURL       = &WebhookError{Code: "INVALID_URL", Message: "url must be an http or https URL"}
+	ErrWebhookAddress   = &WebhookError{Code: "FORBIDDEN_ADDRESS", Message: "url must resolve to a public address"}
+)
+
+// SubscriberError describes why a notification subscriber was rejected
+type SubscriberError struct {
+	Code    string
+	Message string
+}
+
+func (e *SubscriberError) Error() string {
+	return e.Message
+}
+
+var ErrSubscriberLanguage = &SubscriberError{Code: "INVALID_LANGUAGE", Message: "language must be id or en"}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// eventChannel is the Redis pub/sub channel shared by all API instances
+const eventChannel = "ispu:events"
+
+// subscriptionBuffer is the number of events a slow client may lag behind before
+// further events are dropped for it
+const subscriptionBuffer = 64
+
+// EventBroker fans live events out to streaming clients. With Redis, events are
+// published to a pub/sub channel so clients of every API instance receive them;
+// without Redis they are delivered in-process.
+type EventBroker struct {
+	redis *redis.Client
+
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	sinks       []EventSink
+	outbox      EventOutbox
+}
+
+// EventSink receives every event published on this instance. Unlike subscribers,
+// sinks see each event once across all instances, which makes them suitable for
+// persisting events.
+type EventSink interface {
+	Handle(event model.LiveEvent)
+}
+
+// EventOutbox stores events in the transaction that commits the change behind
+// them, so an event is recorded if and only if its change is
+type EventOutbox interface {
+	Stage(tx *gorm.DB, events []model.LiveEvent) error
+}
+
+func NewEventBroker(redis *redis.Client) *EventBroker {
+	return &EventBroker{
+		redis:       redis,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// EventFilter selects the events a client receives. Unless AllStations is set, a
+// station matches when its ID, code or province is selected. An empty Types set
+// matches every event type.
+type EventFilter struct {
+	AllStations  bool
+	StationIDs   map[uint]bool
+	StationCodes map[string]bool
+	Provinces    map[string]bool
+	Types        map[string]bool
+}
+
+// Matches reports whether an event passes the filter
+func (f EventFilter) Matches(event *model.LiveEvent) bool {
+	if len(f.Types) > 0 && !f.Types[event.Type] {
+		return false
+	}
+	if f.AllStations {
+		return true
+	}
+	return f.StationIDs[event.StationID] || f.StationCodes[event.StationCode] || f.Provinces[event.Province]
+}
+
+// Subscription is one client's event feed
+type Subscription struct {
+	events chan model.LiveEvent
+
+	mu     sync.RWMutex
+	filter EventFilter
+}
+
+// Events returns the channel the subscription's events arrive on
+func (s *Subscription) Events() <-chan model.LiveEvent {
+	return s.events
+}
+
+// SetFilter replaces the events the subscription selects
+func (s *Subscription) SetFilter(filter EventFilter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.filter = filter
+}
+
+// Filter returns the events the subscription selects
+func (s *Subscription) Filter() EventFilter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.filter
+}
+
+func (s *Subscription) matches(event *model.LiveEvent) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.filter.Matches(event)
+}
+
+// Subscribe registers a client feed. Callers must Unsubscribe when done.
+func (b *EventBroker) Subscribe(filter EventFilter) *Subscription {
+	sub := &Subscription{
+		events: make(chan model.LiveEvent, subscriptionBuffer),
+		filter: filter,
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Unsubscribe removes a client feed and closes its channel
+func (b *EventBroker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// AddSink registers a sink for the events published on this instance
+func (b *EventBroker) AddSink(sink EventSink) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sinks = append(b.sinks, sink)
+}
+
+// SetOutbox registers the outbox events are staged in
+func (b *EventBroker) SetOutbox(outbox EventOutbox) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.outbox = outbox
+}
+
+// Stage stores events in the outbox within tx, the transaction committing their
+// change. Staged events are still to be published once tx commits.
+func (b *EventBroker) Stage(tx *gorm.DB, events []model.LiveEvent) error {
+	b.mu.RLock()
+	outbox := b.outbox
+	b.mu.RUnlock()
+	if outbox == nil || len(events) == 0 {
+		return nil
+	}
+	return outbox.Stage(tx, events)
+}
+
+// Publish hands an event to the sinks and sends it to every matching subscriber of
+// every instance. When Redis is unavailable or publishing fails, only local
+// subscribers receive it.
+func (b *EventBroker) Publish(event model.LiveEvent) {
+	b.mu.RLock()
+	sinks := b.sinks
+	b.mu.RUnlock()
+	for _, sink := range sinks {
+		sink.Handle(event)
+	}
+
+	if b.redis != nil {
+		payload, err := json.Marshal(event)
+		if err == nil {
+			err = b.redis.Publish(context.Background(), eventChannel, payload).Err()
+		}
+		if err == nil {
+			// Delivered locally when it comes back through the subscription in Run
+			return
+		}
+		log.Printf("Error publishing live event, delivering locally: %v", err)
+	}
+	b.dispatch(&event)
+}
+
+// Run relays events published by any instance to the local subscribers until ctx
+// is cancelled. It returns immediately without Redis.
+func (b *EventBroker) Run(ctx context.Context) {
+	if b.redis == nil {
+		return
+	}
+
+	pubsub := b.redis.Subscribe(ctx, eventChannel)
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var event model.LiveEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				log.Printf("Error decoding live event: %v", err)
+				continue
+			}
+			b.dispatch(&event)
+		}
+	}
+}
+
+// dispatch delivers an event to the matching local subscribers without blocking
+// on slow ones
+func (b *EventBroker) dispatch(event *model.LiveEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		if !sub.matches(event) {
+			continue
+		}
+		select {
+		case sub.events <- *event:
+		default:
+		}
+	}
+}
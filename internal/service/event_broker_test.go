@@ -0,0 +1,60 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+type recordingOutbox struct {
+	staged []model.LiveEvent
+	err    error
+}
+
+func (o *recordingOutbox) Stage(tx *gorm.DB, events []model.LiveEvent) error {
+	o.staged = append(o.staged, events...)
+	return o.err
+}
+
+func TestEventBrokerStage(t *testing.T) {
+	events := []model.LiveEvent{{Type: model.LiveEventReading}, {Type: model.LiveEventCategoryChange}}
+
+	t.Run("without an outbox", func(t *testing.T) {
+		broker := NewEventBroker(nil)
+		if err := broker.Stage(nil, events); err != nil {
+			t.Errorf("stage = %v, want nil", err)
+		}
+	})
+
+	t.Run("hands events to the outbox", func(t *testing.T) {
+		broker := NewEventBroker(nil)
+		outbox := &recordingOutbox{}
+		broker.SetOutbox(outbox)
+		if err := broker.Stage(nil, events); err != nil {
+			t.Fatalf("stage = %v, want nil", err)
+		}
+		if len(outbox.staged) != len(events) {
+			t.Errorf("staged %d events, want %d", len(outbox.staged), len(events))
+		}
+	})
+
+	t.Run("returns the outbox error so the change rolls back", func(t *testing.T) {
+		broker := NewEventBroker(nil)
+		failure := errors.New("outbox unavailable")
+		broker.SetOutbox(&recordingOutbox{err: failure})
+		if err := broker.Stage(nil, events); !errors.Is(err, failure) {
+			t.Errorf("stage = %v, want %v", err, failure)
+		}
+	})
+
+	t.Run("skips an empty event list", func(t *testing.T) {
+		broker := NewEventBroker(nil)
+		outbox := &recordingOutbox{err: errors.New("called")}
+		broker.SetOutbox(outbox)
+		if err := broker.Stage(nil, nil); err != nil {
+			t.Errorf("stage = %v, want nil", err)
+		}
+	})
+}
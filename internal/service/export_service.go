@@ -0,0 +1,111 @@
+package service
+
+import (
+	"io"
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/repository"
+)
+
+// exportHeader names the export columns
+var exportHeader = []interface{}{
+	"station_code", "station_name", "province", "city", "latitude", "longitude",
+	"timestamp", "pm25", "pm10", "co", "no2", "o3", "so2", "hc",
+	"ispu", "category", "critical_pollutant", "qc_status",
+	"qc_pm25", "qc_pm10", "qc_co", "qc_no2", "qc_o3", "qc_so2", "qc_hc",
+}
+
+// exportPollutants is the column order of the pollutant and QC columns
+var exportPollutants = []model.Pollutant{
+	model.PollutantPM25, model.PollutantPM10, model.PollutantCO, model.PollutantNO2,
+	model.PollutantO3, model.PollutantSO2, model.PollutantHC,
+}
+
+// ExportService writes historical measurements as spreadsheets
+type ExportService struct {
+	airQualityRepo *repository.AirQualityRepository
+	categoryRepo   *repository.CategoryRepository
+}
+
+func NewExportService(
+	airQualityRepo *repository.AirQualityRepository,
+	categoryRepo *repository.CategoryRepository,
+) *ExportService {
+	return &ExportService{
+		airQualityRepo: airQualityRepo,
+		categoryRepo:   categoryRepo,
+	}
+}
+
+// Export streams the readings of the stations within [startDate, endDate) to w in
+// the given format, one row per reading with station metadata, all pollutants,
+// ISPU, category and QC flags. Timestamps are written in each station's time zone.
+func (s *ExportService) Export(w io.Writer, format string, stations []model.Station, startDate, endDate time.Time, qc model.QCFilter) error {
+	writer, err := newExportWriter(format, w)
+	if err != nil {
+		return err
+	}
+	if err := writer.WriteRow(exportHeader); err != nil {
+		return err
+	}
+
+	byID := make(map[uint]*model.Station, len(stations))
+	ids := make([]uint, 0, len(stations))
+	for i := range stations {
+		byID[stations[i].ID] = &stations[i]
+		ids = append(ids, stations[i].ID)
+	}
+	locations := make(map[uint]*time.Location, len(stations))
+	for id, station := range byID {
+		locations[id] = station.Location()
+	}
+	categories, _ := s.categoryRepo.GetAll()
+
+	row := make([]interface{}, 0, len(exportHeader))
+	err = s.airQualityRepo.StreamHistory(ids, startDate, endDate, qc, func(data *model.AirQuality) error {
+		station := byID[data.StationID]
+		var category string
+		if data.ISPU != nil {
+			category, _ = categorize(*data.ISPU, categories)
+		}
+
+		row = append(row[:0],
+			station.Code, station.Name, station.Province, station.City, station.Latitude, station.Longitude,
+			data.Timestamp.In(locations[data.StationID]).Format(time.RFC3339),
+		)
+		for _, pollutant := range exportPollutants {
+			row = append(row, optionalFloat(data.Concentration(pollutant)))
+		}
+		row = append(row, optionalInt(data.ISPU), optionalString(category), string(data.CriticalPollutant), string(data.QCStatus))
+		for _, pollutant := range exportPollutants {
+			row = append(row, optionalString(string(data.QCFlags.Get(pollutant))))
+		}
+		return writer.WriteRow(row)
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+func optionalFloat(value *float64) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func optionalInt(value *int) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func optionalString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+// idwPower is the inverse-distance weighting exponent
+const idwPower = 2
+
+// MaxHeatmapCells bounds the size of a single heatmap grid
+const MaxHeatmapCells = 250000
+
+// IndonesiaBounds is the default heatmap extent
+var IndonesiaBounds = model.BoundingBox{MinLon: 94.0, MinLat: -11.5, MaxLon: 141.5, MaxLat: 6.5}
+
+// HeatmapService interpolates the latest station readings over grids and map tiles.
+// Results are cached for cacheTTL, which should match the data refresh cycle.
+// Readings older than maxAge and offline stations are left out, so a dead station
+// does not colour the map with its last value.
+type HeatmapService struct {
+	airQualityRepo *repository.AirQualityRepository
+	categoryRepo   *repository.CategoryRepository
+	redis          *redis.Client
+	cacheTTL       time.Duration
+	maxAge         time.Duration
+
+	// Tiles fall back to an in-process cache when Redis is unavailable
+	tiles *memoryCache
+
+	mu        sync.Mutex
+	samples   []idwSample
+	samplesAt time.Time
+}
+
+func NewHeatmapService(
+	airQualityRepo *repository.AirQualityRepository,
+	categoryRepo *repository.CategoryRepository,
+	redis *redis.Client,
+	cacheTTL time.Duration,
+	maxAge time.Duration,
+) *HeatmapService {
+	return &HeatmapService{
+		airQualityRepo: airQualityRepo,
+		categoryRepo:   categoryRepo,
+		redis:          redis,
+		cacheTTL:       cacheTTL,
+		maxAge:         maxAge,
+		tiles:          newMemoryCache(maxMemoryTiles),
+	}
+}
+
+// GetGrid interpolates the latest ISPU of every station over a grid of resolution
+// degrees covering bbox. Stations further than radiusKm from a cell do not
+// influence it. Grids are cached per extent, resolution and radius.
+func (s *HeatmapService) GetGrid(bbox model.BoundingBox, resolution, radiusKm float64) (*model.HeatmapGrid, error) {
+	cacheKey := fmt.Sprintf("heatmap:%g,%g,%g,%g:%g:%g", bbox.MinLon, bbox.MinLat, bbox.MaxLon, bbox.MaxLat, resolution, radiusKm)
+	ctx := context.Background()
+	if s.redis != nil {
+		cached, err := s.redis.Get(ctx, cacheKey).Result()
+		if err == nil {
+			var grid model.HeatmapGrid
+			if err := json.Unmarshal([]byte(cached), &grid); err == nil {
+				return &grid, nil
+			}
+		}
+	}
+
+	field, err := s.newField(radiusKm)
+	if err != nil {
+		return nil, err
+	}
+	categories, _ := s.categoryRepo.GetAll()
+
+	rows := int(math.Ceil((bbox.MaxLat - bbox.MinLat) / resolution))
+	cols := int(math.Ceil((bbox.MaxLon - bbox.MinLon) / resolution))
+	grid := &model.HeatmapGrid{
+		BBox:        bbox,
+		Resolution:  resolution,
+		RadiusKm:    radiusKm,
+		Rows:        rows,
+		Cols:        cols,
+		Cells:       make([]model.HeatmapCell, 0),
+		GeneratedAt: time.Now(),
+	}
+
+	for row := 0; row < rows; row++ {
+		lat := bbox.MinLat + (float64(row)+0.5)*resolution
+		for col := 0; col < cols; col++ {
+			lon := bbox.MinLon + (float64(col)+0.5)*resolution
+			value, ok := field.at(lat, lon)
+			if !ok {
+				continue
+			}
+
+			ispu := int(math.Round(value))
+			category, color := categorize(ispu, categories)
+			grid.Cells = append(grid.Cells, model.HeatmapCell{
+				Row:       row,
+				Col:       col,
+				Latitude:  lat,
+				Longitude: lon,
+				ISPU:      ispu,
+				Category:  category,
+				Color:     color,
+			})
+		}
+	}
+
+	if s.redis != nil {
+		data, _ := json.Marshal(grid)
+		s.redis.Set(ctx, cacheKey, data, s.cacheTTL)
+	}
+	return grid, nil
+}
+
+// newField builds an interpolation field from the latest reading of every station
+// that is recent, has an ISPU and comes from a station that is not offline.
+// The readings are loaded at most once per cache period, since a single map view
+// requests many tiles at once.
+func (s *HeatmapService) newField(radiusKm float64) (*idwField, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.samples == nil || time.Since(s.samplesAt) > s.cacheTTL {
+		latest, err := s.airQualityRepo.GetLatestForAllStations(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		samples := make([]idwSample, 0, len(latest))
+		for _, data := range latest {
+			if data.Station == nil || data.ISPU == nil || data.Station.Status == model.StationStatusOffline {
+				continue
+			}
+			if s.maxAge > 0 && now.Sub(data.Timestamp) > s.maxAge {
+				continue
+			}
+			samples = append(samples, idwSample{
+				lat:   data.Station.Latitude,
+				lon:   data.Station.Longitude,
+				value: float64(*data.ISPU),
+			})
+		}
+		s.samples = samples
+		s.samplesAt = now
+	}
+
+	return &idwField{samples: s.samples, radiusKm: radiusKm}, nil
+}
+
+// idwSample is a measured value at a station
+type idwSample struct {
+	lat, lon, value float64
+}
+
+// idwField interpolates samples by inverse-distance weighting within a maximum
+// influence radius
+type idwField struct {
+	samples  []idwSample
+	radiusKm float64
+}
+
+// at returns the interpolated value at a point, or false when no sample lies
+// within the influence radius
+func (f *idwField) at(lat, lon float64) (float64, bool) {
+	// One degree of latitude is about 111 km; skip samples clearly out of range
+	latRange := f.radiusKm / 111.0
+
+	weighted, weights := 0.0, 0.0
+	for _, sample := range f.samples {
+		if math.Abs(sample.lat-lat) > latRange {
+			continue
+		}
+		distance := haversineKm(lat, lon, sample.lat, sample.lon)
+		if distance > f.radiusKm {
+			continue
+		}
+		// A cell on top of a station takes its value
+		if distance < 0.001 {
+			return sample.value, true
+		}
+
+		weight := 1 / math.Pow(distance, idwPower)
+		weighted += weight * sample.value
+		weights += weight
+	}
+	if weights == 0 {
+		return 0, false
+	}
+	return weighted / weights, true
+}
+
+// reaches reports whether any sample can influence a point inside bbox
+func (f *idwField) reaches(bbox model.BoundingBox) bool {
+	latRange := f.radiusKm / 111.0
+	for _, sample := range f.samples {
+		lonRange := 180.0
+		if cos := math.Cos(sample.lat * math.Pi / 180); cos > 0.01 {
+			lonRange = latRange / cos
+		}
+		if sample.lat >= bbox.MinLat-latRange && sample.lat <= bbox.MaxLat+latRange &&
+			sample.lon >= bbox.MinLon-lonRange && sample.lon <= bbox.MaxLon+lonRange {
+			return true
+		}
+	}
+	return false
+}
+
+// haversineKm returns the great-circle distance between two points
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := math.Pi / 180
+
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	a := math.Pow(math.Sin(dLat/2), 2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"math"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+)
+
+// ispuBreakpoints are the ISPU index boundaries shared by every pollutant
+var ispuBreakpoints = []float64{0, 50, 100, 200, 300, 500}
+
+// concentrationBreakpoints are the concentration boundaries (µg/m³) matching
+// ispuBreakpoints, taken from Permen LHK No. 14 Tahun 2020
+var concentrationBreakpoints = map[model.Pollutant][]float64{
+	model.PollutantPM10: {0, 50, 150, 350, 420, 500},
+	model.PollutantPM25: {0, 15.5, 55.4, 150.4, 250.4, 500},
+	model.PollutantSO2:  {0, 52, 180, 400, 800, 1200},
+	model.PollutantCO:   {0, 4000, 8000, 15000, 30000, 45000},
+	model.PollutantO3:   {0, 120, 235, 400, 800, 1000},
+	model.PollutantNO2:  {0, 80, 200, 1130, 2260, 3000},
+	model.PollutantHC:   {0, 45, 100, 215, 432, 648},
+}
+
+// CalculateSubIndex converts a pollutant concentration (µg/m³) to its ISPU sub-index
+// by linear interpolation between the regulatory breakpoints. Concentrations above
+// the last breakpoint are reported as the maximum index.
+func CalculateSubIndex(pollutant model.Pollutant, concentration float64) (int, bool) {
+	breakpoints, ok := concentrationBreakpoints[pollutant]
+	if !ok {
+		return 0, false
+	}
+
+	if concentration <= 0 {
+		return 0, true
+	}
+
+	last := len(breakpoints) - 1
+	if concentration >= breakpoints[last] {
+		return int(ispuBreakpoints[last]), true
+	}
+
+	for i := 1; i <= last; i++ {
+		if concentration <= breakpoints[i] {
+			xb, xa := breakpoints[i-1], breakpoints[i]
+			ib, ia := ispuBreakpoints[i-1], ispuBreakpoints[i]
+			index := (ia-ib)/(xa-xb)*(concentration-xb) + ib
+			return int(math.Round(index)), true
+		}
+	}
+
+	return int(ispuBreakpoints[last]), true
+}
+
+// ISPUResult is the outcome of an ISPU calculation for a single reading
+type ISPUResult struct {
+	ISPU              int
+	SubIndices        model.SubIndices
+	CriticalPollutant model.Pollutant
+}
+
+// CalculateISPU computes the sub-index of every reported pollutant and takes the
+// highest one as the ISPU; that pollutant is the critical parameter ("parameter
+// kritis"). Pollutants flagged invalid by quality control are skipped. It returns
+// false when no usable pollutant concentration is present.
+func CalculateISPU(data *model.AirQuality) (*ISPUResult, bool) {
+	result := &ISPUResult{}
+	found := false
+
+	for _, pollutant := range model.Pollutants {
+		concentration := data.Concentration(pollutant)
+		if concentration == nil || data.QCFlags.Get(pollutant) == model.QCInvalid {
+			continue
+		}
+
+		subIndex, ok := CalculateSubIndex(pollutant, *concentration)
+		if !ok {
+			continue
+		}
+		result.SubIndices.Set(pollutant, &subIndex)
+
+		if !found || subIndex > result.ISPU {
+			result.ISPU = subIndex
+			result.CriticalPollutant = pollutant
+		}
+		found = true
+	}
+
+	return result, found
+}
+
+// ScoreAirQuality flags the concentrations of a reading through quality control
+// and stores its ISPU, sub-indices and critical pollutant. Invalid values are kept
+// for traceability but do not contribute to the ISPU, and without any valid
+// pollutant the ISPU stays null rather than reading as Baik.
+func ScoreAirQuality(data *model.AirQuality) {
+	applyQualityControl(data)
+	if result, ok := CalculateISPU(data); ok {
+		data.ISPU = &result.ISPU
+		data.SubIndices = result.SubIndices
+		data.CriticalPollutant = result.CriticalPollutant
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+)
+
+func TestCalculateSubIndex(t *testing.T) {
+	tests := []struct {
+		name          string
+		pollutant     model.Pollutant
+		concentration float64
+		want          int
+		wantOK        bool
+	}{
+		{"zero", model.PollutantPM25, 0, 0, true},
+		{"negative", model.PollutantPM25, -3, 0, true},
+		{"pm25 baik upper bound", model.PollutantPM25, 15.5, 50, true},
+		{"pm25 just above baik", model.PollutantPM25, 15.6, 50, true},
+		{"pm25 inside sedang", model.PollutantPM25, 35, 74, true},
+		{"pm25 sedang upper bound", model.PollutantPM25, 55.4, 100, true},
+		{"pm25 tidak sehat upper bound", model.PollutantPM25, 150.4, 200, true},
+		{"pm25 sangat tidak sehat upper bound", model.PollutantPM25, 250.4, 300, true},
+		{"pm25 top breakpoint", model.PollutantPM25, 500, 500, true},
+		{"pm25 above top band", model.PollutantPM25, 800, 500, true},
+		{"pm10 baik upper bound", model.PollutantPM10, 50, 50, true},
+		{"pm10 rounds half up", model.PollutantPM10, 51, 51, true},
+		{"pm10 inside sedang", model.PollutantPM10, 100, 75, true},
+		{"pm10 inside berbahaya", model.PollutantPM10, 460, 400, true},
+		{"so2 baik upper bound", model.PollutantSO2, 52, 50, true},
+		{"co sedang upper bound", model.PollutantCO, 8000, 100, true},
+		{"co top breakpoint", model.PollutantCO, 45000, 500, true},
+		{"o3 baik upper bound", model.PollutantO3, 120, 50, true},
+		{"o3 sedang upper bound", model.PollutantO3, 235, 100, true},
+		{"no2 baik upper bound", model.PollutantNO2, 80, 50, true},
+		{"hc baik upper bound", model.PollutantHC, 45, 50, true},
+		{"unknown pollutant", model.ParameterISPU, 10, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := CalculateSubIndex(tt.pollutant, tt.concentration)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("CalculateSubIndex(%s, %v) = %d, %v; want %d, %v",
+					tt.pollutant, tt.concentration, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCalculateISPU(t *testing.T) {
+	tests := []struct {
+		name         string
+		reading      model.AirQuality
+		want         int
+		wantCritical model.Pollutant
+		wantSubs     map[model.Pollutant]int
+		wantOK       bool
+	}{
+		{
+			name:         "highest sub-index wins",
+			reading:      model.AirQuality{PM25: floatPtr(35), PM10: floatPtr(100)},
+			want:         75,
+			wantCritical: model.PollutantPM10,
+			wantSubs:     map[model.Pollutant]int{model.PollutantPM25: 74, model.PollutantPM10: 75},
+			wantOK:       true,
+		},
+		{
+			name:         "ties keep the first pollutant in reporting order",
+			reading:      model.AirQuality{PM25: floatPtr(15.5), PM10: floatPtr(50)},
+			want:         50,
+			wantCritical: model.PollutantPM10,
+			wantSubs:     map[model.Pollutant]int{model.PollutantPM25: 50, model.PollutantPM10: 50},
+			wantOK:       true,
+		},
+		{
+			name:         "above the top band",
+			reading:      model.AirQuality{PM25: floatPtr(800), CO: floatPtr(1000)},
+			want:         500,
+			wantCritical: model.PollutantPM25,
+			wantSubs:     map[model.Pollutant]int{model.PollutantPM25: 500, model.PollutantCO: 13},
+			wantOK:       true,
+		},
+		{
+			name: "invalid pollutants are skipped",
+			reading: model.AirQuality{
+				PM25:    floatPtr(35),
+				PM10:    floatPtr(20),
+				QCFlags: model.QCFlags{PM25: model.QCInvalid, PM10: model.QCValid},
+			},
+			want:         20,
+			wantCritical: model.PollutantPM10,
+			wantSubs:     map[model.Pollutant]int{model.PollutantPM10: 20},
+			wantOK:       true,
+		},
+		{
+			name:     "missing pollutants",
+			reading:  model.AirQuality{},
+			wantSubs: map[model.Pollutant]int{},
+		},
+		{
+			name: "every pollutant invalid",
+			reading: model.AirQuality{
+				PM25:    floatPtr(-1),
+				QCFlags: model.QCFlags{PM25: model.QCInvalid},
+			},
+			wantSubs: map[model.Pollutant]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := CalculateISPU(&tt.reading)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if result.ISPU != tt.want || result.CriticalPollutant != tt.wantCritical {
+				t.Errorf("ISPU = %d (%s), want %d (%s)",
+					result.ISPU, result.CriticalPollutant, tt.want, tt.wantCritical)
+			}
+			for _, pollutant := range model.Pollutants {
+				got := result.SubIndices.Get(pollutant)
+				want, ok := tt.wantSubs[pollutant]
+				switch {
+				case !ok && got != nil:
+					t.Errorf("%s sub-index = %d, want none", pollutant, *got)
+				case ok && (got == nil || *got != want):
+					t.Errorf("%s sub-index = %v, want %d", pollutant, got, want)
+				}
+			}
+		})
+	}
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer accepts one connection and answers it with a minimal SMTP
+// dialogue, sending the commands and message data it received on the channel
+func fakeSMTPServer(t *testing.T, greet bool) (host string, port int, received <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	out := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if !greet {
+			// Hold the connection open without a greeting
+			time.Sleep(time.Second)
+			return
+		}
+
+		var transcript strings.Builder
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			transcript.WriteString(line)
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"):
+				reply("250-localhost")
+				reply("250 8BITMIME")
+			case command == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					transcript.WriteString(line)
+				}
+				reply("250 queued")
+			case command == "QUIT":
+				reply("221 bye")
+				out <- transcript.String()
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, out
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	host, port, received := fakeSMTPServer(t, true)
+	mailer, err := NewSMTPMailer(host, port, "", "", "ISPU Monitoring <noreply@example.com>", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mailer.Send("Budi <budi@example.com>", "Peringatan ISPU", "ISPU 180\nTIDAK SEHAT"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	select {
+	case transcript := <-received:
+		for _, want := range []string{
+			"MAIL FROM:<noreply@example.com>",
+			"RCPT TO:<budi@example.com>",
+			"Subject: Peringatan ISPU\r\n",
+			"ISPU 180\r\nTIDAK SEHAT\r\n",
+		} {
+			if !strings.Contains(transcript, want) {
+				t.Errorf("server did not receive %q in:\n%s", want, transcript)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not receive the message")
+	}
+}
+
+func TestSMTPMailerTimesOut(t *testing.T) {
+	host, port, _ := fakeSMTPServer(t, false)
+	mailer, err := NewSMTPMailer(host, port, "", "", "noreply@example.com", 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	err = mailer.Send("budi@example.com", "Peringatan ISPU", "ISPU 180")
+	if err == nil {
+		t.Fatal("send to a server that never greets succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("send gave up after %v, want about 100ms", elapsed)
+	}
+}
+
+func TestSMTPMailerRequiresAuthSupport(t *testing.T) {
+	host, port, _ := fakeSMTPServer(t, true)
+	mailer, err := NewSMTPMailer(host, port, "user", "secret", "noreply@example.com", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mailer.Send("budi@example.com", "Peringatan ISPU", "ISPU 180"); err == nil {
+		t.Fatal("send with credentials to a server without AUTH succeeded")
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"math"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+)
+
+// ClusterMaxZoom is the first zoom level at which stations are no longer clustered
+const ClusterMaxZoom = 10
+
+// Web map tiles are 256 pixels wide; stations within the same clusterCellPixels
+// square on screen are merged into one cluster
+const (
+	tileSize          = 256
+	clusterCellPixels = 80
+)
+
+// worldPixel projects a point to Web Mercator pixel coordinates at a zoom level
+func worldPixel(lat, lon float64, zoom int) (float64, float64) {
+	// Mercator is undefined at the poles
+	lat = math.Max(-85.05112878, math.Min(85.05112878, lat))
+	scale := tileSize * math.Exp2(float64(zoom))
+
+	x := (lon + 180) / 360 * scale
+	sinLat := math.Sin(lat * math.Pi / 180)
+	y := (0.5 - math.Log((1+sinLat)/(1-sinLat))/(4*math.Pi)) * scale
+	return x, y
+}
+
+// filterBounds keeps the stations inside a bounding box
+func filterBounds(stations []model.StationWithAirQuality, bbox model.BoundingBox) []model.StationWithAirQuality {
+	filtered := make([]model.StationWithAirQuality, 0, len(stations))
+	for _, station := range stations {
+		if bbox.Contains(station.Latitude, station.Longitude) {
+			filtered = append(filtered, station)
+		}
+	}
+	return filtered
+}
+
+// clusterStations merges stations falling in the same screen-space grid cell at a
+// zoom level. Cells holding a single station keep it as an individual marker.
+func clusterStations(stations []model.StationWithAirQuality, zoom int, categories []model.ISPUCategory) *model.MapClusters {
+	type cell struct{ x, y int }
+
+	cells := make(map[cell][]model.StationWithAirQuality)
+	var order []cell
+	for _, station := range stations {
+		x, y := worldPixel(station.Latitude, station.Longitude, zoom)
+		key := cell{int(x / clusterCellPixels), int(y / clusterCellPixels)}
+		if _, ok := cells[key]; !ok {
+			order = append(order, key)
+		}
+		cells[key] = append(cells[key], station)
+	}
+
+	result := &model.MapClusters{
+		Zoom:     zoom,
+		Clusters: make([]model.MapCluster, 0),
+		Stations: make([]model.StationWithAirQuality, 0),
+	}
+	for _, key := range order {
+		members := cells[key]
+		if len(members) == 1 {
+			result.Stations = append(result.Stations, members[0])
+			continue
+		}
+		result.Clusters = append(result.Clusters, buildCluster(members, categories))
+	}
+	return result
+}
+
+// buildCluster summarizes the stations of one grid cell
+func buildCluster(members []model.StationWithAirQuality, categories []model.ISPUCategory) model.MapCluster {
+	cluster := model.MapCluster{
+		Count:      len(members),
+		WorstISPU:  -1,
+		StationIDs: make([]uint, 0, len(members)),
+		Bounds: model.BoundingBox{
+			MinLon: members[0].Longitude, MinLat: members[0].Latitude,
+			MaxLon: members[0].Longitude, MaxLat: members[0].Latitude,
+		},
+	}
+
+	total := 0
+	for _, station := range members {
+		cluster.Latitude += station.Latitude
+		cluster.Longitude += station.Longitude
+		cluster.StationIDs = append(cluster.StationIDs, station.ID)
+		total += station.ISPU
+		if station.ISPU > cluster.WorstISPU {
+			cluster.WorstISPU = station.ISPU
+		}
+
+		cluster.Bounds.MinLon = math.Min(cluster.Bounds.MinLon, station.Longitude)
+		cluster.Bounds.MinLat = math.Min(cluster.Bounds.MinLat, station.Latitude)
+		cluster.Bounds.MaxLon = math.Max(cluster.Bounds.MaxLon, station.Longitude)
+		cluster.Bounds.MaxLat = math.Max(cluster.Bounds.MaxLat, station.Latitude)
+	}
+
+	count := float64(len(members))
+	cluster.Latitude /= count
+	cluster.Longitude /= count
+	cluster.AverageISPU = float64(total) / count
+	cluster.WorstCategory, cluster.WorstColor = categorize(cluster.WorstISPU, categories)
+	return cluster
+}
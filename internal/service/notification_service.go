@@ -0,0 +1,378 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/repository"
+)
+
+const (
+	// unhealthyISPU is the lowest ISPU of the Tidak Sehat category; stations getting
+	// worse at or above it trigger alert emails
+	unhealthyISPU = 101
+	// notificationQueueSize is the number of events waiting for emails before new
+	// ones are dropped
+	notificationQueueSize = 256
+	// digestRows is the number of stations listed in a daily digest
+	digestRows = 10
+)
+
+// ErrMailerDisabled is returned when emails are requested without an SMTP server
+var ErrMailerDisabled = errors.New("email notifications are disabled; set SMTP_HOST to enable them")
+
+// NotificationService emails subscribers when their stations turn unhealthy or an
+// alert rule fires, and sends a daily digest of the previous day's worst readings.
+// A subscriber gets at most one alert email per station per cooldown and at most
+// maxPerHour alert emails per hour, so a flapping station does not flood inboxes.
+type NotificationService struct {
+	repo           *repository.NotificationRepository
+	stationRepo    *repository.StationRepository
+	airQualityRepo *repository.AirQualityRepository
+	categoryRepo   *repository.CategoryRepository
+	mailer         Mailer
+	cooldown       time.Duration
+	maxPerHour     int
+	digestHour     int
+	queue          chan model.LiveEvent
+}
+
+func NewNotificationService(
+	repo *repository.NotificationRepository,
+	stationRepo *repository.StationRepository,
+	airQualityRepo *repository.AirQualityRepository,
+	categoryRepo *repository.CategoryRepository,
+	mailer Mailer,
+	cooldown time.Duration,
+	maxPerHour int,
+	digestHour int,
+) *NotificationService {
+	return &NotificationService{
+		repo:           repo,
+		stationRepo:    stationRepo,
+		airQualityRepo: airQualityRepo,
+		categoryRepo:   categoryRepo,
+		mailer:         mailer,
+		cooldown:       cooldown,
+		maxPerHour:     maxPerHour,
+		digestHour:     digestHour,
+		queue:          make(chan model.LiveEvent, notificationQueueSize),
+	}
+}
+
+func (s *NotificationService) ListSubscribers() ([]model.NotificationSubscriber, error) {
+	return s.repo.GetSubscribers()
+}
+
+func (s *NotificationService) GetSubscriber(id uint) (*model.NotificationSubscriber, error) {
+	return s.repo.GetSubscriber(id)
+}
+
+func (s *NotificationService) CreateSubscriber(subscriber *model.NotificationSubscriber) error {
+	if err := s.validateSubscriber(subscriber); err != nil {
+		return err
+	}
+	return s.repo.CreateSubscriber(subscriber)
+}
+
+func (s *NotificationService) UpdateSubscriber(id uint, subscriber *model.NotificationSubscriber) (*model.NotificationSubscriber, error) {
+	existing, err := s.repo.GetSubscriber(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateSubscriber(subscriber); err != nil {
+		return nil, err
+	}
+
+	subscriber.ID = existing.ID
+	subscriber.CreatedAt = existing.CreatedAt
+	if err := s.repo.UpdateSubscriber(subscriber); err != nil {
+		return nil, err
+	}
+	return subscriber, nil
+}
+
+func (s *NotificationService) DeleteSubscriber(id uint) error {
+	return s.repo.DeleteSubscriber(id)
+}
+
+func (s *NotificationService) ListLog(filter model.NotificationLogFilter) ([]model.NotificationLog, error) {
+	return s.repo.ListLog(filter)
+}
+
+// validateSubscriber checks the language and followed stations and fills defaults
+func (s *NotificationService) validateSubscriber(subscriber *model.NotificationSubscriber) error {
+	subscriber.Email = strings.ToLower(strings.TrimSpace(subscriber.Email))
+
+	switch subscriber.Language {
+	case "":
+		subscriber.Language = model.LanguageIndonesian
+	case model.LanguageIndonesian, model.LanguageEnglish:
+	default:
+		return ErrSubscriberLanguage
+	}
+
+	for _, code := range subscriber.StationCodes {
+		if _, err := s.stationRepo.GetByCode(code); err != nil {
+			return &SubscriberError{Code: "STATION_NOT_FOUND", Message: fmt.Sprintf("Station %s does not exist", code)}
+		}
+	}
+	if subscriber.StationCodes == nil {
+		subscriber.StationCodes = model.StringList{}
+	}
+	if subscriber.Provinces == nil {
+		subscriber.Provinces = model.StringList{}
+	}
+
+	enabled := true
+	for _, flag := range []**bool{&subscriber.AlertEmails, &subscriber.DigestEmails, &subscriber.Enabled} {
+		if *flag == nil {
+			*flag = &enabled
+		}
+	}
+	return nil
+}
+
+// Handle queues alert emails for an event. It is registered as an event sink, so
+// each event is handled once across instances; emails are sent by Run so ingest
+// never waits on the mail server.
+func (s *NotificationService) Handle(event model.LiveEvent) {
+	if s.mailer == nil {
+		return
+	}
+	switch event.Type {
+	case model.LiveEventAlertFiring, model.LiveEventCategoryChange:
+	default:
+		return
+	}
+
+	select {
+	case s.queue <- event:
+	default:
+		log.Printf("Notification queue full, dropping %s event of station %d", event.Type, event.StationID)
+	}
+}
+
+// Run sends queued alert emails and the daily digest, due at digestHour WIB for the
+// previous day, until ctx is cancelled
+func (s *NotificationService) Run(ctx context.Context) {
+	if s.mailer == nil {
+		log.Println("SMTP_HOST is not set, email notifications are disabled")
+		return
+	}
+
+	wib, _ := model.LoadTimezone(model.TimezoneWIB)
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	var lastDigest string
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-s.queue:
+			s.notify(&event)
+		case now := <-ticker.C:
+			now = now.In(wib)
+			today := now.Format("2006-01-02")
+			if now.Hour() < s.digestHour || lastDigest == today {
+				continue
+			}
+			lastDigest = today
+			if count, err := s.SendDigest(now.AddDate(0, 0, -1)); err != nil {
+				log.Printf("Error sending daily digest: %v", err)
+			} else if count > 0 {
+				log.Printf("Sent %d daily digest emails", count)
+			}
+		}
+	}
+}
+
+// notify emails the subscribers following the station of an event that signals
+// worsening air quality
+func (s *NotificationService) notify(event *model.LiveEvent) {
+	station, err := s.stationRepo.GetByID(event.StationID)
+	if err != nil {
+		log.Printf("Error loading station %d for notifications: %v", event.StationID, err)
+		return
+	}
+
+	var reference string
+	data := alertEmail{
+		StationName: station.Name,
+		StationCode: station.Code,
+		Province:    station.Province,
+		Time:        event.Timestamp.In(station.Location()).Format("02-01-2006 15:04 MST"),
+	}
+	switch payload := event.Data.(type) {
+	case model.Alert:
+		if payload.Rule == nil {
+			return
+		}
+		reference = fmt.Sprintf("alert:%d", payload.ID)
+		data.RuleName = payload.Rule.Name
+		data.Parameter = payload.Rule.Parameter
+		data.Value = payload.Value
+		data.Threshold = payload.Threshold
+	case model.CategoryChange:
+		if payload.ISPU < unhealthyISPU || payload.ISPU <= payload.PreviousISPU {
+			return
+		}
+		reference = fmt.Sprintf("category:%d:%d", station.ID, payload.Timestamp.Unix())
+		data.ISPU = payload.ISPU
+		data.Category = payload.Category
+		data.Pollutant = payload.CriticalPollutant
+	default:
+		return
+	}
+
+	subscribers, err := s.repo.GetEnabledSubscribers(model.NotificationAlert)
+	if err != nil {
+		log.Printf("Error loading notification subscribers: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range subscribers {
+		subscriber := &subscribers[i]
+		if !subscriber.Follows(station.Code, station.Province) || s.rateLimited(subscriber, station.ID, now) {
+			continue
+		}
+
+		data.SubscriberName = subscriber.Name
+		subject, body, err := localized(alertTemplates, subscriber.Language).render(data)
+		if err != nil {
+			log.Printf("Error rendering alert email: %v", err)
+			return
+		}
+		stationID := station.ID
+		s.deliver(subscriber, &model.NotificationLog{
+			SubscriberID: subscriber.ID,
+			StationID:    &stationID,
+			Kind:         model.NotificationAlert,
+			Reference:    reference,
+			Subject:      subject,
+		}, body)
+	}
+}
+
+// rateLimited reports whether a subscriber already got an alert email about the
+// station within the cooldown, or reached the hourly limit
+func (s *NotificationService) rateLimited(subscriber *model.NotificationSubscriber, stationID uint, now time.Time) bool {
+	if s.cooldown > 0 {
+		count, err := s.repo.CountSince(subscriber.ID, &stationID, now.Add(-s.cooldown))
+		if err != nil || count > 0 {
+			return true
+		}
+	}
+	if s.maxPerHour > 0 {
+		count, err := s.repo.CountSince(subscriber.ID, nil, now.Add(-time.Hour))
+		if err != nil || count >= int64(s.maxPerHour) {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver claims a notification and sends it. It returns false when the email was
+// already sent before, is being sent by another attempt or sending failed. A
+// failed email is claimed and sent again on the next attempt.
+func (s *NotificationService) deliver(subscriber *model.NotificationSubscriber, entry *model.NotificationLog, body string) bool {
+	entry.Status = model.NotificationPending
+	claimed, err := s.repo.Claim(entry)
+	if err != nil {
+		log.Printf("Error recording notification for %s: %v", subscriber.Email, err)
+		return false
+	}
+	if !claimed {
+		return false
+	}
+
+	if err := s.mailer.Send(subscriber.Email, entry.Subject, body); err != nil {
+		entry.Status = model.NotificationFailed
+		entry.Error = err.Error()
+		log.Printf("Error emailing %s: %v", subscriber.Email, err)
+	} else {
+		now := time.Now()
+		entry.Status = model.NotificationSent
+		entry.SentAt = &now
+	}
+	if err := s.repo.Finish(entry); err != nil {
+		log.Printf("Error recording notification %d: %v", entry.ID, err)
+	}
+	return entry.Status == model.NotificationSent
+}
+
+// SendDigest emails every digest subscriber the worst readings of their stations
+// on a WIB calendar day and returns the number of emails sent. Subscribers already
+// sent the digest of that day are skipped.
+func (s *NotificationService) SendDigest(date time.Time) (int, error) {
+	if s.mailer == nil {
+		return 0, ErrMailerDisabled
+	}
+
+	wib, _ := model.LoadTimezone(model.TimezoneWIB)
+	dayStart := model.LocalDay(date, wib)
+	day := dayStart.Format("2006-01-02")
+
+	peaks, err := s.airQualityRepo.GetPeakReadings(dayStart, dayStart.AddDate(0, 0, 1))
+	if err != nil {
+		return 0, err
+	}
+	categories, err := s.categoryRepo.GetAll()
+	if err != nil {
+		return 0, err
+	}
+	subscribers, err := s.repo.GetEnabledSubscribers(model.NotificationDigest)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range subscribers {
+		subscriber := &subscribers[i]
+		data := digestEmail{SubscriberName: subscriber.Name, Date: day}
+		for _, peak := range peaks {
+			if peak.Station == nil || peak.ISPU == nil || !subscriber.Follows(peak.Station.Code, peak.Station.Province) {
+				continue
+			}
+			category, _ := categorize(*peak.ISPU, categories)
+			data.Rows = append(data.Rows, digestRow{
+				StationName: peak.Station.Name,
+				StationCode: peak.Station.Code,
+				Province:    peak.Station.Province,
+				Time:        peak.Timestamp.In(peak.Station.Location()).Format("15:04 MST"),
+				ISPU:        *peak.ISPU,
+				Category:    category,
+				Pollutant:   peak.CriticalPollutant,
+			})
+		}
+		sort.SliceStable(data.Rows, func(a, b int) bool {
+			return data.Rows[a].ISPU > data.Rows[b].ISPU
+		})
+		if len(data.Rows) > digestRows {
+			data.Rows = data.Rows[:digestRows]
+		}
+
+		subject, body, err := localized(digestTemplates, subscriber.Language).render(data)
+		if err != nil {
+			return sent, err
+		}
+		entry := &model.NotificationLog{
+			SubscriberID: subscriber.ID,
+			Kind:         model.NotificationDigest,
+			Reference:    "digest:" + day,
+			Subject:      subject,
+		}
+		if s.deliver(subscriber, entry, body) {
+			sent++
+		}
+	}
+	return sent, nil
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+)
+
+// alertEmail is the data of an alert email
+type alertEmail struct {
+	SubscriberName string
+	StationName    string
+	StationCode    string
+	Province       string
+	Time           string
+	ISPU           int
+	Category       string
+	Pollutant      model.Pollutant
+	// Rule fields are set for emails about a firing alert rule
+	RuleName  string
+	Parameter model.Pollutant
+	Value     float64
+	Threshold float64
+}
+
+// digestEmail is the data of a daily digest email
+type digestEmail struct {
+	SubscriberName string
+	Date           string
+	Rows           []digestRow
+}
+
+type digestRow struct {
+	StationName string
+	StationCode string
+	Province    string
+	Time        string
+	ISPU        int
+	Category    string
+	Pollutant   model.Pollutant
+}
+
+// emailTemplate is a localized subject and body
+type emailTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+func newEmailTemplate(subject, body string) emailTemplate {
+	return emailTemplate{
+		subject: template.Must(template.New("subject").Parse(subject)),
+		body:    template.Must(template.New("body").Parse(body)),
+	}
+}
+
+func (t emailTemplate) render(data interface{}) (string, string, error) {
+	var subject, body bytes.Buffer
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return "", "", err
+	}
+	if err := t.body.Execute(&body, data); err != nil {
+		return "", "", err
+	}
+	return subject.String(), body.String(), nil
+}
+
+var alertTemplates = map[string]emailTemplate{
+	model.LanguageIndonesian: newEmailTemplate(
+		`[ISPU] {{.StationName}}: {{if .RuleName}}{{.RuleName}}{{else}}{{.Category}}{{end}}`,
+		`Yth. {{.SubscriberName}},
+
+Kualitas udara di stasiun {{.StationName}} ({{.StationCode}}, {{.Province}}) memburuk.
+{{if .RuleName}}
+Aturan peringatan "{{.RuleName}}" aktif: {{.Parameter}} bernilai {{printf "%.1f" .Value}}, di atas ambang {{printf "%.1f" .Threshold}}.
+{{else}}
+ISPU saat ini {{.ISPU}} ({{.Category}}), dengan parameter kritis {{.Pollutant}}.
+{{end}}
+Waktu pengukuran: {{.Time}}
+
+Kurangi aktivitas di luar ruangan dan gunakan masker bila perlu.
+
+--
+Sistem Monitoring ISPU
+`),
+	model.LanguageEnglish: newEmailTemplate(
+		`[ISPU] {{.StationName}}: {{if .RuleName}}{{.RuleName}}{{else}}{{.Category}}{{end}}`,
+		`Dear {{.SubscriberName}},
+
+Air quality at station {{.StationName}} ({{.StationCode}}, {{.Province}}) has deteriorated.
+{{if .RuleName}}
+Alert rule "{{.RuleName}}" is firing: {{.Parameter}} is {{printf "%.1f" .Value}}, above the threshold of {{printf "%.1f" .Threshold}}.
+{{else}}
+The current ISPU is {{.ISPU}} ({{.Category}}), with {{.Pollutant}} as the critical pollutant.
+{{end}}
+Measured at: {{.Time}}
+
+Limit outdoor activities and wear a mask if needed.
+
+--
+ISPU Monitoring System
+`),
+}
+
+var digestTemplates = map[string]emailTemplate{
+	model.LanguageIndonesian: newEmailTemplate(
+		`[ISPU] Ringkasan kualitas udara {{.Date}}`,
+		`Yth. {{.SubscriberName}},
+
+Berikut ISPU tertinggi per stasiun pada {{.Date}}:
+{{range .Rows}}
+- {{.StationName}} ({{.StationCode}}, {{.Province}}): ISPU {{.ISPU}} ({{.Category}}), parameter kritis {{.Pollutant}}, pukul {{.Time}}{{else}}
+Tidak ada data pengukuran dari stasiun yang Anda ikuti.{{end}}
+
+--
+Sistem Monitoring ISPU
+`),
+	model.LanguageEnglish: newEmailTemplate(
+		`[ISPU] Air quality digest for {{.Date}}`,
+		`Dear {{.SubscriberName}},
+
+These were the highest ISPU readings per station on {{.Date}}:
+{{range .Rows}}
+- {{.StationName}} ({{.StationCode}}, {{.Province}}): ISPU {{.ISPU}} ({{.Category}}), critical pollutant {{.Pollutant}}, at {{.Time}}{{else}}
+None of the stations you follow reported any readings.{{end}}
+
+--
+ISPU Monitoring System
+`),
+}
+
+// localized returns the template in a language, Indonesian when unknown
+func localized(templates map[string]emailTemplate, language string) emailTemplate {
+	if t, ok := templates[language]; ok {
+		return t
+	}
+	return templates[model.LanguageIndonesian]
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"sort"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/repository"
+)
+
+// trimPage cuts a measurement list fetched with one extra row down to the page
+// limit and describes the page
+func trimPage(items []model.AirQuality, limit int) ([]model.AirQuality, *model.Pagination) {
+	pagination := &model.Pagination{Limit: limit}
+	if limit <= 0 || len(items) <= limit {
+		pagination.Limit = len(items)
+		return items, pagination
+	}
+
+	items = items[:limit]
+	pagination.HasMore = true
+	pagination.NextCursor = model.CursorOf(&items[limit-1]).Encode()
+	return items, pagination
+}
+
+// sortMeasurements orders a copy of a measurement list held in memory by
+// (timestamp, id)
+func sortMeasurements(items []model.AirQuality, ascending bool) []model.AirQuality {
+	sorted := make([]model.AirQuality, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return cursorBefore(model.CursorOf(&sorted[i]), model.CursorOf(&sorted[j]), ascending)
+	})
+	return sorted
+}
+
+// cursorBefore reports whether a comes before b in (timestamp, id) order
+func cursorBefore(a, b model.Cursor, ascending bool) bool {
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return a.Timestamp.Before(b.Timestamp) == ascending
+	}
+	if a.ID != b.ID {
+		return (a.ID < b.ID) == ascending
+	}
+	return false
+}
+
+// IsStationSortKey reports whether a sort value (optionally prefixed with "-" for
+// descending order) names a sortable station field
+func IsStationSortKey(value string) bool {
+	return repository.IsStationSortKey(value)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+)
+
+// qcLimit is the plausible range of a pollutant concentration (µg/m³). Values above
+// suspect are rare but possible (e.g. during forest fire haze); values above invalid
+// cannot come from a working analyzer.
+type qcLimit struct {
+	suspect float64
+	invalid float64
+}
+
+var qcLimits = map[model.Pollutant]qcLimit{
+	model.PollutantPM10: {suspect: 1500, invalid: 10000},
+	model.PollutantPM25: {suspect: 1000, invalid: 5000},
+	model.PollutantSO2:  {suspect: 2000, invalid: 10000},
+	model.PollutantCO:   {suspect: 60000, invalid: 200000},
+	model.PollutantO3:   {suspect: 1000, invalid: 2000},
+	model.PollutantNO2:  {suspect: 3000, invalid: 10000},
+	model.PollutantHC:   {suspect: 1000, invalid: 10000},
+}
+
+// futureTolerance absorbs clock drift on field devices
+const futureTolerance = 10 * time.Minute
+
+// applyQualityControl flags every reported pollutant as valid, suspect or invalid
+// and sets the overall QC status of the reading to the worst flag
+func applyQualityControl(data *model.AirQuality) {
+	for _, pollutant := range model.Pollutants {
+		concentration := data.Concentration(pollutant)
+		if concentration == nil {
+			continue
+		}
+		data.QCFlags.Set(pollutant, checkRange(pollutant, *concentration))
+	}
+
+	// PM2.5 is a fraction of PM10, so a larger PM2.5 means one of them is wrong
+	if data.PM25 != nil && data.PM10 != nil && *data.PM25 > *data.PM10 {
+		for _, pollutant := range []model.Pollutant{model.PollutantPM25, model.PollutantPM10} {
+			if data.QCFlags.Get(pollutant) == model.QCValid {
+				data.QCFlags.Set(pollutant, model.QCSuspect)
+			}
+		}
+	}
+
+	data.QCStatus = data.QCFlags.Worst()
+}
+
+func checkRange(pollutant model.Pollutant, concentration float64) model.QCFlag {
+	if concentration < 0 {
+		return model.QCInvalid
+	}
+
+	limit, ok := qcLimits[pollutant]
+	if !ok {
+		return model.QCValid
+	}
+
+	switch {
+	case concentration > limit.invalid:
+		return model.QCInvalid
+	case concentration > limit.suspect:
+		return model.QCSuspect
+	}
+	return model.QCValid
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+)
+
+func TestApplyQualityControl(t *testing.T) {
+	tests := []struct {
+		name       string
+		reading    model.AirQuality
+		wantFlags  map[model.Pollutant]model.QCFlag
+		wantStatus model.QCFlag
+		wantISPU   bool
+	}{
+		{
+			name:       "plausible values",
+			reading:    model.AirQuality{PM25: floatPtr(20), PM10: floatPtr(40), CO: floatPtr(1000)},
+			wantFlags:  map[model.Pollutant]model.QCFlag{model.PollutantPM25: model.QCValid, model.PollutantPM10: model.QCValid, model.PollutantCO: model.QCValid},
+			wantStatus: model.QCValid,
+			wantISPU:   true,
+		},
+		{
+			name:       "upper suspect bound is valid",
+			reading:    model.AirQuality{O3: floatPtr(1000)},
+			wantFlags:  map[model.Pollutant]model.QCFlag{model.PollutantO3: model.QCValid},
+			wantStatus: model.QCValid,
+			wantISPU:   true,
+		},
+		{
+			name:       "above the suspect bound",
+			reading:    model.AirQuality{PM10: floatPtr(2000), SO2: floatPtr(10)},
+			wantFlags:  map[model.Pollutant]model.QCFlag{model.PollutantPM10: model.QCSuspect, model.PollutantSO2: model.QCValid},
+			wantStatus: model.QCSuspect,
+			wantISPU:   true,
+		},
+		{
+			name:       "above the invalid bound",
+			reading:    model.AirQuality{NO2: floatPtr(20000), HC: floatPtr(30)},
+			wantFlags:  map[model.Pollutant]model.QCFlag{model.PollutantNO2: model.QCInvalid, model.PollutantHC: model.QCValid},
+			wantStatus: model.QCInvalid,
+			wantISPU:   true,
+		},
+		{
+			name:       "negative concentration",
+			reading:    model.AirQuality{CO: floatPtr(-5), PM10: floatPtr(30)},
+			wantFlags:  map[model.Pollutant]model.QCFlag{model.PollutantCO: model.QCInvalid, model.PollutantPM10: model.QCValid},
+			wantStatus: model.QCInvalid,
+			wantISPU:   true,
+		},
+		{
+			name:       "pm25 above pm10",
+			reading:    model.AirQuality{PM25: floatPtr(60), PM10: floatPtr(40)},
+			wantFlags:  map[model.Pollutant]model.QCFlag{model.PollutantPM25: model.QCSuspect, model.PollutantPM10: model.QCSuspect},
+			wantStatus: model.QCSuspect,
+			wantISPU:   true,
+		},
+		{
+			name:       "pm25 above pm10 keeps an invalid flag",
+			reading:    model.AirQuality{PM25: floatPtr(6000), PM10: floatPtr(40)},
+			wantFlags:  map[model.Pollutant]model.QCFlag{model.PollutantPM25: model.QCInvalid, model.PollutantPM10: model.QCSuspect},
+			wantStatus: model.QCInvalid,
+			wantISPU:   true,
+		},
+		{
+			name:       "every pollutant invalid",
+			reading:    model.AirQuality{PM25: floatPtr(-1), O3: floatPtr(5000)},
+			wantFlags:  map[model.Pollutant]model.QCFlag{model.PollutantPM25: model.QCInvalid, model.PollutantO3: model.QCInvalid},
+			wantStatus: model.QCInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reading := tt.reading
+			applyQualityControl(&reading)
+
+			for _, pollutant := range model.Pollutants {
+				if got := reading.QCFlags.Get(pollutant); got != tt.wantFlags[pollutant] {
+					t.Errorf("%s flag = %q, want %q", pollutant, got, tt.wantFlags[pollutant])
+				}
+			}
+			if reading.QCStatus != tt.wantStatus {
+				t.Errorf("status = %q, want %q", reading.QCStatus, tt.wantStatus)
+			}
+			if _, ok := CalculateISPU(&reading); ok != tt.wantISPU {
+				t.Errorf("ISPU computed = %v, want %v", ok, tt.wantISPU)
+			}
+		})
+	}
+}
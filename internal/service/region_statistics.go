@@ -0,0 +1,132 @@
+package service
+
+import (
+	"sort"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+)
+
+// regionStatistic accumulates the latest readings of the active stations in a region
+type regionStatistic struct {
+	stationCount int64
+	reporting    int64
+	totalISPU    int
+	worst        *model.StationWithAirQuality
+	best         *model.StationWithAirQuality
+}
+
+func (r *regionStatistic) add(reading *model.StationWithAirQuality) {
+	r.stationCount++
+	if reading == nil {
+		return
+	}
+
+	r.reporting++
+	r.totalISPU += reading.ISPU
+	if r.worst == nil || reading.ISPU > r.worst.ISPU {
+		r.worst = reading
+	}
+	if r.best == nil || reading.ISPU < r.best.ISPU {
+		r.best = reading
+	}
+}
+
+func (r *regionStatistic) averageISPU() float64 {
+	if r.reporting == 0 {
+		return 0
+	}
+	return float64(r.totalISPU) / float64(r.reporting)
+}
+
+func (r *regionStatistic) categories() (worst string, best string, worstStation string) {
+	if r.worst != nil {
+		worst, worstStation = r.worst.Category, r.worst.Name
+	}
+	if r.best != nil {
+		best = r.best.Category
+	}
+	return worst, best, worstStation
+}
+
+func (r *regionStatistic) provinceStatistic(province string) model.ProvinceStatistic {
+	worst, best, worstStation := r.categories()
+	return model.ProvinceStatistic{
+		Province:          province,
+		StationCount:      r.stationCount,
+		ReportingStations: r.reporting,
+		AverageISPU:       r.averageISPU(),
+		WorstCategory:     worst,
+		BestCategory:      best,
+		WorstStation:      worstStation,
+	}
+}
+
+// buildProvinceStatistic summarises the stations of a single province
+func buildProvinceStatistic(province string, stations []model.Station, readings map[uint]*model.StationWithAirQuality) model.ProvinceStatistic {
+	region := &regionStatistic{}
+	for _, station := range stations {
+		region.add(readings[station.ID])
+	}
+	return region.provinceStatistic(province)
+}
+
+// buildProvinceStatistics groups active stations by province using their latest readings.
+// Provinces are ordered by average ISPU, worst first.
+func buildProvinceStatistics(stations []model.Station, readings map[uint]*model.StationWithAirQuality) []model.ProvinceStatistic {
+	regions := make(map[string]*regionStatistic)
+	for _, station := range stations {
+		if station.Province == "" {
+			continue
+		}
+		if regions[station.Province] == nil {
+			regions[station.Province] = &regionStatistic{}
+		}
+		regions[station.Province].add(readings[station.ID])
+	}
+
+	stats := make([]model.ProvinceStatistic, 0, len(regions))
+	for province, region := range regions {
+		stats = append(stats, region.provinceStatistic(province))
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].AverageISPU != stats[j].AverageISPU {
+			return stats[i].AverageISPU > stats[j].AverageISPU
+		}
+		return stats[i].Province < stats[j].Province
+	})
+	return stats
+}
+
+// buildCityStatistics groups the active stations of one province by city
+func buildCityStatistics(stations []model.Station, readings map[uint]*model.StationWithAirQuality) []model.CityStatistic {
+	regions := make(map[string]*regionStatistic)
+	for _, station := range stations {
+		if regions[station.City] == nil {
+			regions[station.City] = &regionStatistic{}
+		}
+		regions[station.City].add(readings[station.ID])
+	}
+
+	stats := make([]model.CityStatistic, 0, len(regions))
+	for city, region := range regions {
+		worst, best, worstStation := region.categories()
+		stats = append(stats, model.CityStatistic{
+			City:              city,
+			StationCount:      region.stationCount,
+			ReportingStations: region.reporting,
+			AverageISPU:       region.averageISPU(),
+			WorstCategory:     worst,
+			BestCategory:      best,
+			WorstStation:      worstStation,
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].AverageISPU != stats[j].AverageISPU {
+			return stats[i].AverageISPU > stats[j].AverageISPU
+		}
+		return stats[i].City < stats[j].City
+	})
+	return stats
+}
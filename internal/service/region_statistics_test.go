@@ -0,0 +1,105 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+)
+
+func TestBuildProvinceStatistic(t *testing.T) {
+	stations := []model.Station{
+		{ID: 1, Province: "DKI Jakarta"},
+		{ID: 2, Province: "DKI Jakarta"},
+		{ID: 3, Province: "DKI Jakarta"},
+	}
+	readings := map[uint]*model.StationWithAirQuality{
+		1: {ID: 1, Name: "Bundaran HI", ISPU: 150, Category: "Tidak Sehat"},
+		2: {ID: 2, Name: "Kelapa Gading", ISPU: 50, Category: "Baik"},
+	}
+
+	stat := buildProvinceStatistic("DKI Jakarta", stations, readings)
+
+	if stat.Province != "DKI Jakarta" || stat.StationCount != 3 || stat.ReportingStations != 2 {
+		t.Errorf("got %s with %d stations, %d reporting; want DKI Jakarta with 3 stations, 2 reporting",
+			stat.Province, stat.StationCount, stat.ReportingStations)
+	}
+	if stat.AverageISPU != 100 {
+		t.Errorf("average ISPU = %v, want 100", stat.AverageISPU)
+	}
+	if stat.WorstCategory != "Tidak Sehat" || stat.BestCategory != "Baik" || stat.WorstStation != "Bundaran HI" {
+		t.Errorf("worst %s at %s, best %s; want Tidak Sehat at Bundaran HI, best Baik",
+			stat.WorstCategory, stat.WorstStation, stat.BestCategory)
+	}
+}
+
+func TestBuildProvinceStatisticWithoutProvinceName(t *testing.T) {
+	stations := []model.Station{{ID: 1}}
+
+	stat := buildProvinceStatistic("", stations, nil)
+
+	if stat.StationCount != 1 || stat.ReportingStations != 0 {
+		t.Errorf("got %d stations, %d reporting; want 1 station, 0 reporting", stat.StationCount, stat.ReportingStations)
+	}
+}
+
+func TestBuildProvinceStatistics(t *testing.T) {
+	stations := []model.Station{
+		{ID: 1, Province: "Jawa Barat"},
+		{ID: 2, Province: "DKI Jakarta"},
+		{ID: 3, Province: "Banten"},
+		{ID: 4, Province: "Bali"},
+		{ID: 5},
+	}
+	readings := map[uint]*model.StationWithAirQuality{
+		1: {ID: 1, ISPU: 80, Category: "Sedang"},
+		2: {ID: 2, ISPU: 120, Category: "Tidak Sehat"},
+		3: {ID: 3, ISPU: 80, Category: "Sedang"},
+		5: {ID: 5, ISPU: 300, Category: "Berbahaya"},
+	}
+
+	stats := buildProvinceStatistics(stations, readings)
+
+	// Worst average first, ties by name, stations without a province left out
+	want := []string{"DKI Jakarta", "Banten", "Jawa Barat", "Bali"}
+	if len(stats) != len(want) {
+		t.Fatalf("got %d provinces, want %d", len(stats), len(want))
+	}
+	for i, province := range want {
+		if stats[i].Province != province {
+			t.Errorf("stats[%d] = %s, want %s", i, stats[i].Province, province)
+		}
+	}
+	if bali := stats[3]; bali.StationCount != 1 || bali.ReportingStations != 0 || bali.AverageISPU != 0 || bali.WorstCategory != "" {
+		t.Errorf("Bali = %+v, want 1 silent station without categories", bali)
+	}
+}
+
+func TestBuildCityStatistics(t *testing.T) {
+	stations := []model.Station{
+		{ID: 1, City: "Jakarta Pusat"},
+		{ID: 2, City: "Jakarta Pusat"},
+		{ID: 3, City: "Jakarta Utara"},
+		{ID: 4, City: "Jakarta Selatan"},
+	}
+	readings := map[uint]*model.StationWithAirQuality{
+		1: {ID: 1, Name: "Bundaran HI", ISPU: 160, Category: "Tidak Sehat"},
+		2: {ID: 2, Name: "Monas", ISPU: 40, Category: "Baik"},
+		3: {ID: 3, Name: "Kelapa Gading", ISPU: 60, Category: "Sedang"},
+	}
+
+	stats := buildCityStatistics(stations, readings)
+
+	want := []model.CityStatistic{
+		{City: "Jakarta Pusat", StationCount: 2, ReportingStations: 2, AverageISPU: 100, WorstCategory: "Tidak Sehat", BestCategory: "Baik", WorstStation: "Bundaran HI"},
+		{City: "Jakarta Utara", StationCount: 1, ReportingStations: 1, AverageISPU: 60, WorstCategory: "Sedang", BestCategory: "Sedang", WorstStation: "Kelapa Gading"},
+		{City: "Jakarta Selatan", StationCount: 1},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("got %d cities, want %d", len(stats), len(want))
+	}
+	for i := range want {
+		if stats[i] != want[i] {
+			t.Errorf("stats[%d] = %+v, want %+v", i, stats[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/repository"
+)
+
+// rebuildChunk bounds the amount of raw data recomputed in one transaction
+const rebuildChunk = 31 * 24 * time.Hour
+
+// RollupService maintains the hourly and daily aggregate tables
+type RollupService struct {
+	repo           *repository.RollupRepository
+	airQualityRepo *repository.AirQualityRepository
+}
+
+func NewRollupService(repo *repository.RollupRepository, airQualityRepo *repository.AirQualityRepository) *RollupService {
+	return &RollupService{
+		repo:           repo,
+		airQualityRepo: airQualityRepo,
+	}
+}
+
+// rollupKey identifies a bucket touched by new readings
+type rollupKey struct {
+	period    string
+	stationID uint
+	start     time.Time
+}
+
+// RefreshReadings recomputes the hourly and daily buckets containing the given
+// readings. Each bucket is refreshed once no matter how many readings fall in it.
+func (s *RollupService) RefreshReadings(readings []*model.AirQuality) {
+	seen := make(map[rollupKey]bool)
+
+	for _, reading := range readings {
+		for _, period := range []string{model.PeriodHour, model.PeriodDay} {
+			start, end := bucketBounds(period, reading.Timestamp)
+			key := rollupKey{period: period, stationID: reading.StationID, start: start}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			if err := s.repo.Refresh(period, reading.StationID, start, end); err != nil {
+				log.Printf("Error refreshing %s rollup for station %d: %v", period, reading.StationID, err)
+			}
+		}
+	}
+}
+
+// Rebuild recomputes both rollups over [from, to). A stationID of 0 rebuilds all stations.
+func (s *RollupService) Rebuild(stationID uint, from, to time.Time) error {
+	// Widen the range to whole days so no bucket is left half computed
+	from, _ = bucketBounds(model.PeriodDay, from)
+	_, to = bucketBounds(model.PeriodDay, to)
+
+	return s.rebuild([]string{model.PeriodHour, model.PeriodDay}, stationID, from, to)
+}
+
+// RebuildDaily recomputes every daily bucket of a station, whose local days move
+// when its time zone changes. Hourly buckets do not depend on the time zone.
+func (s *RollupService) RebuildDaily(stationID uint) error {
+	earliest, err := s.airQualityRepo.GetEarliestTimestamp()
+	if err != nil || earliest == nil {
+		return err
+	}
+	from, _ := bucketBounds(model.PeriodDay, *earliest)
+	_, to := bucketBounds(model.PeriodDay, time.Now())
+	return s.rebuild([]string{model.PeriodDay}, stationID, from, to)
+}
+
+// rebuild refreshes the buckets of the given periods in chunks of raw data
+func (s *RollupService) rebuild(periods []string, stationID uint, from, to time.Time) error {
+	for start := from; start.Before(to); start = start.Add(rebuildChunk) {
+		end := start.Add(rebuildChunk)
+		if end.After(to) {
+			end = to
+		}
+		for _, period := range periods {
+			if err := s.repo.Refresh(period, stationID, start, end); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EnsureBuilt rebuilds the rollups from all raw data when they have never been built
+func (s *RollupService) EnsureBuilt() {
+	empty, err := s.repo.IsEmpty()
+	if err != nil || !empty {
+		return
+	}
+
+	earliest, err := s.airQualityRepo.GetEarliestTimestamp()
+	if err != nil || earliest == nil {
+		return
+	}
+
+	log.Println("Building air quality rollups from raw readings...")
+	if err := s.Rebuild(0, *earliest, time.Now()); err != nil {
+		log.Printf("Error building rollups: %v", err)
+		return
+	}
+	log.Println("Air quality rollups built successfully")
+}
+
+func (s *RollupService) GetAggregates(period string, stationID uint, pollutant model.Pollutant, from, to time.Time) ([]model.AirQualityAggregate, error) {
+	return s.repo.List(period, stationID, pollutant, from, to)
+}
+
+func (s *RollupService) GetAverageISPU() (float64, error) {
+	return s.repo.GetAverageISPU()
+}
+
+// bucketBounds returns a range of bucket starts that includes the bucket of a period
+// containing t. Daily buckets start at the station's local midnight, which for
+// Indonesian zones falls on the UTC day of t or the one before, so the range
+// spans both.
+func bucketBounds(period string, t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	if period == model.PeriodHour {
+		start := t.Truncate(time.Hour)
+		return start, start.Add(time.Hour)
+	}
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return start.AddDate(0, 0, -1), start.AddDate(0, 0, 1)
+}
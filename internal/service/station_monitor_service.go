@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/repository"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// StationMonitorService derives the reporting status of every active station from
+// the time of its latest reading, relative to the station's expected reporting
+// interval, and records each status change in the station's status history
+type StationMonitorService struct {
+	stationRepo    *repository.StationRepository
+	airQualityRepo *repository.AirQualityRepository
+	events         *EventBroker
+	redis          *redis.Client
+	interval       time.Duration
+}
+
+func NewStationMonitorService(
+	stationRepo *repository.StationRepository,
+	airQualityRepo *repository.AirQualityRepository,
+	events *EventBroker,
+	redis *redis.Client,
+	interval time.Duration,
+) *StationMonitorService {
+	return &StationMonitorService{
+		stationRepo:    stationRepo,
+		airQualityRepo: airQualityRepo,
+		events:         events,
+		redis:          redis,
+		interval:       interval,
+	}
+}
+
+// Run checks the stations immediately and then every interval until ctx is cancelled
+func (s *StationMonitorService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if count, err := s.Check(time.Now()); err != nil {
+			log.Printf("Error checking station status: %v", err)
+		} else if count > 0 {
+			log.Printf("Station monitor recorded %d status changes", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check updates the status of every active station as of now and returns the
+// number of status changes recorded
+func (s *StationMonitorService) Check(now time.Time) (int, error) {
+	stations, err := s.stationRepo.GetAll()
+	if err != nil {
+		return 0, err
+	}
+	lastSeen, err := s.airQualityRepo.GetLastSeen()
+	if err != nil {
+		return 0, err
+	}
+
+	changes := 0
+	for i := range stations {
+		station := &stations[i]
+		var seen *time.Time
+		if ts, ok := lastSeen[station.ID]; ok {
+			seen = &ts
+		}
+
+		status := station.ReportingStatus(seen, now)
+		if status == station.Status {
+			if !sameTime(seen, station.LastSeenAt) {
+				if err := s.stationRepo.UpdateLastSeen(station.ID, seen); err != nil {
+					log.Printf("Error updating last reading time of station %s: %v", station.Code, err)
+				}
+			}
+			continue
+		}
+
+		transition := &model.StationStatusTransition{
+			StationID:      station.ID,
+			Status:         status,
+			PreviousStatus: station.Status,
+			LastSeenAt:     seen,
+			ChangedAt:      now,
+		}
+		var event model.LiveEvent
+		recorded, err := s.stationRepo.ChangeStatus(transition, func(tx *gorm.DB) error {
+			event = model.LiveEvent{
+				Type:        model.LiveEventStationStatus,
+				StationID:   station.ID,
+				StationCode: station.Code,
+				Province:    station.Province,
+				Timestamp:   now,
+				Data:        *transition,
+			}
+			return s.events.Stage(tx, []model.LiveEvent{event})
+		})
+		if err != nil {
+			log.Printf("Error changing status of station %s: %v", station.Code, err)
+			continue
+		}
+		if !recorded {
+			continue
+		}
+
+		changes++
+		s.events.Publish(event)
+	}
+
+	// The map and the dashboard carry the status, so their caches are dropped; last
+	// reading times alone may lag until the caches expire
+	if changes > 0 && s.redis != nil {
+		s.redis.Del(context.Background(), "map:stations", "dashboard:overview")
+	}
+	return changes, nil
+}
+
+// sameTime reports whether two optional times are both unset or equal
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -1,58 +1,25 @@
 package service
 
 import (
-	"context"
-	"encoding/json"
-	"fmt"
+	"log"
 	"time"
 
 	"github.com/ispu-monitoring/backend/internal/model"
 	"github.com/ispu-monitoring/backend/internal/repository"
-	"github.com/redis/go-redis/v9"
 )
 
 type StationService struct {
-	repo  *repository.StationRepository
-	redis *redis.Client
+	repo    *repository.StationRepository
+	rollups *RollupService
 }
 
-func NewStationService(repo *repository.StationRepository, redis *redis.Client) *StationService {
+func NewStationService(repo *repository.StationRepository, rollups *RollupService) *StationService {
 	return &StationService{
-		repo:  repo,
-		redis: redis,
+		repo:    repo,
+		rollups: rollups,
 	}
 }
 
-func (s *StationService) GetAllStations() ([]model.Station, error) {
-	// Try cache first
-	if s.redis != nil {
-		cacheKey := "stations:all"
-		ctx := context.Background()
-		
-		cached, err := s.redis.Get(ctx, cacheKey).Result()
-		if err == nil {
-			var stations []model.Station
-			if err := json.Unmarshal([]byte(cached), &stations); err == nil {
-				return stations, nil
-			}
-		}
-		
-		// Fetch from database
-		stations, err := s.repo.GetAll()
-		if err != nil {
-			return nil, err
-		}
-		
-		// Cache for 5 minutes
-		data, _ := json.Marshal(stations)
-		s.redis.Set(ctx, cacheKey, data, 5*time.Minute)
-		
-		return stations, nil
-	}
-	
-	return s.repo.GetAll()
-}
-
 func (s *StationService) GetStationByID(id uint) (*model.Station, error) {
 	return s.repo.GetByID(id)
 }
@@ -61,31 +28,111 @@ func (s *StationService) GetStationsByProvince(province string) ([]model.Station
 	return s.repo.GetByProvince(province)
 }
 
+// ListStations returns one offset page of the active stations, optionally limited
+// to a province and a reporting status and sorted by a station field ("-" prefix
+// for descending order). A limit of 0 returns every station from the offset on.
+func (s *StationService) ListStations(province, status, sortBy string, limit, offset int) ([]model.Station, *model.Pagination, error) {
+	stations, total, err := s.repo.List(model.StationFilter{
+		Province: province,
+		Status:   status,
+		Sort:     sortBy,
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	count := int(total)
+	return stations, &model.Pagination{
+		Limit:   limit,
+		Offset:  &offset,
+		Total:   &count,
+		HasMore: offset+len(stations) < count,
+	}, nil
+}
+
+func (s *StationService) GetStationByCode(code string) (*model.Station, error) {
+	return s.repo.GetByCode(code)
+}
+
+// GetStationLocation returns the time zone of a station's local day
+func (s *StationService) GetStationLocation(id uint) (*time.Location, error) {
+	station, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return station.Location(), nil
+}
+
+// GetStatusHistory returns a station's reporting status transitions, newest first
+func (s *StationService) GetStatusHistory(id uint, limit int) ([]model.StationStatusTransition, error) {
+	if _, err := s.repo.GetByID(id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetStatusHistory(id, limit)
+}
+
 func (s *StationService) CreateStation(station *model.Station) error {
-	// Invalidate cache
-	if s.redis != nil {
-		ctx := context.Background()
-		s.redis.Del(ctx, "stations:all")
+	clearStatus(station)
+	// Stations without an explicit time zone get the zone of their province
+	if station.Timezone == "" {
+		station.Timezone = model.TimezoneFor(station.Province, station.Longitude)
+	}
+	if err := normalizeTimezone(station); err != nil {
+		return err
 	}
 	return s.repo.Create(station)
 }
 
+// UpdateStation changes the fields set on station. A new time zone moves the
+// station's local days, so its daily rollup is rebuilt.
 func (s *StationService) UpdateStation(id uint, station *model.Station) error {
-	// Invalidate cache
-	if s.redis != nil {
-		ctx := context.Background()
-		s.redis.Del(ctx, "stations:all")
-		s.redis.Del(ctx, fmt.Sprintf("station:%d", id))
+	clearStatus(station)
+	if err := normalizeTimezone(station); err != nil {
+		return err
+	}
+
+	timezoneChanged := false
+	if station.Timezone != "" {
+		if existing, err := s.repo.GetByID(id); err == nil {
+			timezoneChanged = existing.Timezone != station.Timezone
+		}
+	}
+
+	if err := s.repo.Update(id, station); err != nil {
+		return err
+	}
+
+	if timezoneChanged {
+		if err := s.rollups.RebuildDaily(id); err != nil {
+			log.Printf("Error rebuilding daily rollup of station %d: %v", id, err)
+		}
 	}
-	return s.repo.Update(id, station)
+	return nil
 }
 
 func (s *StationService) DeleteStation(id uint) error {
-	// Invalidate cache
-	if s.redis != nil {
-		ctx := context.Background()
-		s.redis.Del(ctx, "stations:all")
-		s.redis.Del(ctx, fmt.Sprintf("station:%d", id))
-	}
 	return s.repo.Delete(id)
 }
+
+// normalizeTimezone stores zone abbreviations such as WITA under their IANA name
+func normalizeTimezone(station *model.Station) error {
+	if station.Timezone == "" {
+		return nil
+	}
+	loc, err := model.LoadTimezone(station.Timezone)
+	if err != nil {
+		return err
+	}
+	station.Timezone = loc.String()
+	return nil
+}
+
+// clearStatus drops the status fields of a station sent by a client, as only the
+// station monitor sets them
+func clearStatus(station *model.Station) {
+	station.Status = ""
+	station.LastSeenAt = nil
+	station.StatusChangedAt = nil
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+)
+
+// Tile rendering settings
+const (
+	// tileRadiusKm is the influence radius of a station on tiles
+	tileRadiusKm = 100.0
+	// tileBlock is the size in pixels of the squares interpolated as one value
+	tileBlock = 4
+	// tileAlpha keeps the base map visible under the overlay
+	tileAlpha = 160
+	// maxMemoryTiles bounds the in-process tile cache
+	maxMemoryTiles = 4096
+)
+
+// RenderTile renders the interpolated ISPU colours of XYZ tile z/x/y as a
+// transparent PNG. Pixels beyond the influence radius of every station stay empty.
+func (s *HeatmapService) RenderTile(z, x, y int) ([]byte, error) {
+	cacheKey := fmt.Sprintf("tile:%d/%d/%d", z, x, y)
+	ctx := context.Background()
+	if s.redis != nil {
+		if cached, err := s.redis.Get(ctx, cacheKey).Bytes(); err == nil {
+			return cached, nil
+		}
+	} else if cached, ok := s.tiles.get(cacheKey); ok {
+		return cached, nil
+	}
+
+	field, err := s.newField(tileRadiusKm)
+	if err != nil {
+		return nil, err
+	}
+	categories, _ := s.categoryRepo.GetAll()
+
+	// Colours are parsed once per category instead of once per pixel
+	colors := make(map[string]color.NRGBA)
+	for _, category := range categories {
+		if c, ok := parseHexColor(category.Color); ok {
+			c.A = tileAlpha
+			colors[category.Category] = c
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, tileSize, tileSize))
+	scale := tileSize * math.Exp2(float64(z))
+	// Tiles far from every station stay blank without interpolating each block
+	reached := field.reaches(tileBounds(z, x, y))
+	for by := 0; reached && by < tileSize; by += tileBlock {
+		for bx := 0; bx < tileSize; bx += tileBlock {
+			// Sample the centre of the block
+			px := float64(x*tileSize+bx) + tileBlock/2.0
+			py := float64(y*tileSize+by) + tileBlock/2.0
+			lat, lon := pixelToLatLon(px, py, scale)
+
+			value, ok := field.at(lat, lon)
+			if !ok {
+				continue
+			}
+			category, _ := categorize(int(math.Round(value)), categories)
+			fill, ok := colors[category]
+			if !ok {
+				continue
+			}
+
+			for dy := 0; dy < tileBlock; dy++ {
+				for dx := 0; dx < tileBlock; dx++ {
+					img.SetNRGBA(bx+dx, by+dy, fill)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	tile := buf.Bytes()
+
+	if s.redis != nil {
+		s.redis.Set(ctx, cacheKey, tile, s.cacheTTL)
+	} else {
+		s.tiles.set(cacheKey, tile, s.cacheTTL)
+	}
+	return tile, nil
+}
+
+// CacheTTL is how long rendered grids and tiles stay valid
+func (s *HeatmapService) CacheTTL() time.Duration {
+	return s.cacheTTL
+}
+
+// pixelToLatLon inverts the Web Mercator projection for a world of scale pixels
+func pixelToLatLon(px, py, scale float64) (float64, float64) {
+	lon := px/scale*360 - 180
+	n := math.Pi * (1 - 2*py/scale)
+	lat := math.Atan(math.Sinh(n)) * 180 / math.Pi
+	return lat, lon
+}
+
+// parseHexColor parses a #rrggbb colour
+func parseHexColor(hex string) (color.NRGBA, bool) {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return color.NRGBA{}, false
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, false
+	}
+	return color.NRGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 255}, true
+}
+
+// memoryCache is a size-bounded in-process cache with per-entry expiry
+type memoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	limit   int
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+func newMemoryCache(limit int) *memoryCache {
+	return &memoryCache{entries: make(map[string]memoryEntry), limit: limit}
+}
+
+func (c *memoryCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (c *memoryCache) set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= c.limit {
+		// Drop expired entries first, then everything if the cache is still full
+		now := time.Now()
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.limit {
+			c.entries = make(map[string]memoryEntry)
+		}
+	}
+	c.entries[key] = memoryEntry{value: value, expires: time.Now().Add(ttl)}
+}
+
+// TileExists reports whether z/x/y addresses a tile of the XYZ scheme
+func TileExists(z, x, y int) bool {
+	if z < 0 || z > 22 {
+		return false
+	}
+	n := 1 << uint(z)
+	return x >= 0 && x < n && y >= 0 && y < n
+}
+
+// tileBounds returns the geographic extent of tile z/x/y
+func tileBounds(z, x, y int) model.BoundingBox {
+	scale := tileSize * math.Exp2(float64(z))
+	north, west := pixelToLatLon(float64(x*tileSize), float64(y*tileSize), scale)
+	south, east := pixelToLatLon(float64((x+1)*tileSize), float64((y+1)*tileSize), scale)
+	return model.BoundingBox{MinLon: west, MinLat: south, MaxLon: east, MaxLat: north}
+}
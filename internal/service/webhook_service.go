@@ -0,0 +1,487 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+	"github.com/ispu-monitoring/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+const (
+	// webhookTimeout bounds a single delivery request
+	webhookTimeout = 10 * time.Second
+	// webhookLease keeps a claimed delivery away from other workers while it is sent
+	webhookLease = 5 * time.Minute
+	// webhookBatchSize is the number of deliveries claimed at once
+	webhookBatchSize = 50
+	// webhookConcurrency is the number of deliveries sent in parallel
+	webhookConcurrency = 8
+	// webhookMaxAttempts is the number of attempts before a delivery is given up
+	webhookMaxAttempts = 10
+	// webhookBaseBackoff is the wait after the first failure, doubled on each retry
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+	// webhookCacheTTL is how long the enabled webhooks are kept in memory
+	webhookCacheTTL = 30 * time.Second
+	// webhookResponseLimit is the number of response body bytes kept for debugging
+	webhookResponseLimit = 1024
+)
+
+// Webhook request headers
+const (
+	WebhookSignatureHeader = "X-ISPU-Signature"
+	WebhookTimestampHeader = "X-ISPU-Timestamp"
+	WebhookEventHeader     = "X-ISPU-Event"
+	WebhookDeliveryHeader  = "X-ISPU-Delivery"
+)
+
+// defaultWebhookEvents are subscribed to when a webhook names no event types
+var defaultWebhookEvents = model.StringList{
+	model.LiveEventCategoryChange,
+	model.LiveEventAlertFiring,
+	model.LiveEventAlertResolved,
+	model.LiveEventStationStatus,
+}
+
+// WebhookService delivers live events to partner endpoints. Events are written to
+// an outbox table in the transaction that commits their change and sent by a
+// background worker, which retries failed deliveries with exponential backoff.
+// Delivery is at least once, so receivers should ignore a delivery_id they have
+// already processed.
+//
+// Each request is a POST of {"delivery_id": ..., "event": <live event>} signed
+// with HMAC-SHA256 over "<timestamp>.<body>" using the webhook secret. The hex
+// digest is sent as "sha256=<digest>" in X-ISPU-Signature and the Unix timestamp
+// in X-ISPU-Timestamp, so receivers can reject forged and replayed requests.
+//
+// Webhooks may only target public addresses. The address is checked when a
+// webhook is saved and again on every connection, so a host name later pointed
+// at an internal address is refused too. Redirects are not followed.
+type WebhookService struct {
+	repo      *repository.WebhookRepository
+	client    *http.Client
+	interval  time.Duration
+	retention time.Duration
+
+	mu       sync.Mutex
+	cached   []model.Webhook
+	cachedAt time.Time
+}
+
+func NewWebhookService(repo *repository.WebhookRepository, interval, retention time.Duration) *WebhookService {
+	return &WebhookService{
+		repo:      repo,
+		client:    newWebhookClient(),
+		interval:  interval,
+		retention: retention,
+	}
+}
+
+// ListWebhooks returns every webhook without its secret
+func (s *WebhookService) ListWebhooks() ([]model.Webhook, error) {
+	webhooks, err := s.repo.GetAll()
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, err
+}
+
+// GetWebhook returns a webhook without its secret
+func (s *WebhookService) GetWebhook(id uint) (*model.Webhook, error) {
+	webhook, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+// CreateWebhook stores a webhook, generating its secret unless one is given. The
+// secret is left on the webhook for the caller to hand out once.
+func (s *WebhookService) CreateWebhook(webhook *model.Webhook) error {
+	if err := validateWebhook(webhook); err != nil {
+		return err
+	}
+	if webhook.Secret == "" {
+		secret, err := generateSecret()
+		if err != nil {
+			return err
+		}
+		webhook.Secret = secret
+	}
+
+	defer s.invalidate()
+	return s.repo.Create(webhook)
+}
+
+// UpdateWebhook replaces a webhook. The secret is kept unless a new one is given.
+func (s *WebhookService) UpdateWebhook(id uint, webhook *model.Webhook) (*model.Webhook, error) {
+	existing, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateWebhook(webhook); err != nil {
+		return nil, err
+	}
+
+	webhook.ID = existing.ID
+	webhook.CreatedAt = existing.CreatedAt
+	if webhook.Secret == "" {
+		webhook.Secret = existing.Secret
+	}
+
+	defer s.invalidate()
+	if err := s.repo.Update(webhook); err != nil {
+		return nil, err
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (s *WebhookService) DeleteWebhook(id uint) error {
+	defer s.invalidate()
+	return s.repo.Delete(id)
+}
+
+// ListDeliveries returns the delivery log of a webhook, newest first
+func (s *WebhookService) ListDeliveries(filter model.WebhookDeliveryFilter) ([]model.WebhookDelivery, error) {
+	if _, err := s.repo.GetByID(filter.WebhookID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeliveries(filter)
+}
+
+func validateWebhook(webhook *model.Webhook) error {
+	target, err := url.Parse(webhook.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return ErrWebhookURL
+	}
+	addresses, err := net.LookupIP(target.Hostname())
+	if err != nil {
+		return ErrWebhookURL
+	}
+	for _, address := range addresses {
+		if !publicAddress(address) {
+			return ErrWebhookAddress
+		}
+	}
+
+	if len(webhook.EventTypes) == 0 {
+		webhook.EventTypes = append(model.StringList{}, defaultWebhookEvents...)
+	}
+	for _, eventType := range webhook.EventTypes {
+		if !model.IsLiveEventType(eventType) {
+			return ErrWebhookEventType
+		}
+	}
+
+	if webhook.Enabled == nil {
+		enabled := true
+		webhook.Enabled = &enabled
+	}
+	return nil
+}
+
+// newWebhookClient returns an HTTP client that connects to public addresses only
+// and hands redirects back to the caller instead of following them
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		// Runs on the resolved address of every connection attempt
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicAddress(ip) {
+				return ErrWebhookAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+			MaxIdleConnsPerHost: webhookConcurrency,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// publicAddress reports whether ip is routable on the internet, which rules out
+// loopback, private (RFC 1918 and fc00::/7), link-local including the cloud
+// metadata address 169.254.169.254, unspecified and multicast addresses
+func publicAddress(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+func generateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// Stage queues events for every enabled webhook subscribed to their type within
+// tx, the transaction committing the change behind them, so the deliveries exist
+// exactly when the change does. It is registered as the event outbox.
+func (s *WebhookService) Stage(tx *gorm.DB, events []model.LiveEvent) error {
+	webhooks, err := s.enabledWebhooks()
+	if err != nil {
+		return err
+	}
+
+	var deliveries []model.WebhookDelivery
+	now := time.Now()
+	for _, event := range events {
+		var payload []byte
+		for _, webhook := range webhooks {
+			if !subscribes(webhook, event.Type) {
+				continue
+			}
+			if payload == nil {
+				if payload, err = json.Marshal(event); err != nil {
+					return err
+				}
+			}
+			deliveries = append(deliveries, model.WebhookDelivery{
+				WebhookID:     webhook.ID,
+				EventType:     event.Type,
+				Payload:       string(payload),
+				Status:        model.DeliveryPending,
+				NextAttemptAt: now,
+			})
+		}
+	}
+
+	return s.repo.WithTx(tx).Enqueue(deliveries)
+}
+
+func subscribes(webhook model.Webhook, eventType string) bool {
+	for _, subscribed := range webhook.EventTypes {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// enabledWebhooks returns the enabled webhooks, cached briefly because every
+// ingested reading is checked against them
+func (s *WebhookService) enabledWebhooks() ([]model.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.cachedAt.IsZero() && time.Since(s.cachedAt) < webhookCacheTTL {
+		return s.cached, nil
+	}
+	webhooks, err := s.repo.GetEnabled()
+	if err != nil {
+		return nil, err
+	}
+	s.cached = webhooks
+	s.cachedAt = time.Now()
+	return webhooks, nil
+}
+
+func (s *WebhookService) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cached = nil
+	s.cachedAt = time.Time{}
+}
+
+// Run sends due deliveries on every interval and prunes old finished deliveries
+// once an hour until ctx is cancelled
+func (s *WebhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	var prunedAt time.Time
+
+	for {
+		for {
+			sent, err := s.DeliverDue(ctx)
+			if err != nil {
+				log.Printf("Error delivering webhooks: %v", err)
+			}
+			// A full batch suggests a backlog, so keep going without waiting
+			if err != nil || sent < webhookBatchSize {
+				break
+			}
+		}
+
+		if time.Since(prunedAt) >= time.Hour {
+			if count, err := s.repo.DeleteFinishedBefore(time.Now().Add(-s.retention)); err != nil {
+				log.Printf("Error pruning webhook deliveries: %v", err)
+			} else if count > 0 {
+				log.Printf("Pruned %d old webhook deliveries", count)
+			}
+			prunedAt = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue claims the deliveries that are due and sends them, returning how
+// many were attempted
+func (s *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	deliveries, err := s.repo.ClaimDue(time.Now(), webhookLease, webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, webhookConcurrency)
+	for i := range deliveries {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(delivery *model.WebhookDelivery) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			s.attempt(ctx, delivery)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+// attempt sends a delivery once and records the outcome, scheduling a retry after
+// a failure until the attempts run out
+func (s *WebhookService) attempt(ctx context.Context, delivery *model.WebhookDelivery) {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = nil
+	delivery.ResponseBody = ""
+	delivery.LastError = ""
+
+	// Deliveries of webhooks disabled since they were queued are given up at once
+	giveUp := false
+	switch {
+	case delivery.Webhook == nil:
+		delivery.LastError = "webhook no longer exists"
+		giveUp = true
+	case !*delivery.Webhook.Enabled:
+		delivery.LastError = "webhook is disabled"
+		giveUp = true
+	default:
+		status, body, err := s.send(ctx, delivery)
+		if status != 0 {
+			delivery.ResponseStatus = &status
+		}
+		delivery.ResponseBody = body
+		if err != nil {
+			delivery.LastError = err.Error()
+		}
+	}
+
+	switch {
+	case delivery.LastError == "":
+		delivery.Status = model.DeliveryDelivered
+		delivery.DeliveredAt = &now
+	case giveUp || delivery.Attempts >= webhookMaxAttempts:
+		delivery.Status = model.DeliveryFailed
+	default:
+		delivery.NextAttemptAt = now.Add(webhookBackoff(delivery.Attempts))
+	}
+	delivery.UpdatedAt = now
+
+	if err := s.repo.SaveAttempt(delivery); err != nil {
+		log.Printf("Error recording webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// send posts a signed delivery and returns the response status and the start of
+// the response body. Any status outside 2xx is an error; the body of a redirect
+// is not kept.
+func (s *WebhookService) send(ctx context.Context, delivery *model.WebhookDelivery) (int, string, error) {
+	body, err := json.Marshal(struct {
+		DeliveryID uint            `json:"delivery_id"`
+		Event      json.RawMessage `json:"event"`
+	}{delivery.ID, json.RawMessage(delivery.Payload)})
+	if err != nil {
+		return 0, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ISPU-Monitoring-Webhook/1.0")
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(delivery.Webhook.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 && resp.StatusCode <= 399 {
+		return resp.StatusCode, "", fmt.Errorf("receiver redirected with status %d; redirects are not followed", resp.StatusCode)
+	}
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, string(snippet), fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(snippet), nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>" under secret
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff returns the wait before the next attempt after the given number
+// of attempts, with up to 10% jitter so failed receivers are not hit in lockstep
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookMaxBackoff
+	if attempts <= 16 {
+		if wait := webhookBaseBackoff << (attempts - 1); wait < webhookMaxBackoff {
+			backoff = wait
+		}
+	}
+	return backoff + time.Duration(mathrand.Int63n(int64(backoff/10)+1))
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ispu-monitoring/backend/internal/model"
+)
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.10", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::ffff:10.0.0.1", false},
+		{"224.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if got := publicAddress(net.ParseIP(tt.address)); got != tt.want {
+				t.Errorf("publicAddress(%s) = %v, want %v", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateWebhookRejectsInternalAddresses(t *testing.T) {
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"ftp://93.184.216.34/hook", ErrWebhookURL},
+		{"http://127.0.0.1:8080/hook", ErrWebhookAddress},
+		{"http://10.0.0.5/hook", ErrWebhookAddress},
+		{"http://169.254.169.254/latest/meta-data", ErrWebhookAddress},
+		{"http://[::1]/hook", ErrWebhookAddress},
+		{"https://93.184.216.34/hook", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := validateWebhook(&model.Webhook{URL: tt.url})
+			if err != tt.want {
+				t.Errorf("validateWebhook(%s) = %v, want %v", tt.url, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookClientRefusesInternalAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	s := &WebhookService{client: newWebhookClient()}
+	delivery := &model.WebhookDelivery{
+		EventType: model.LiveEventReading,
+		Payload:   "{}",
+		Webhook:   &model.Webhook{URL: server.URL, Secret: "secret"},
+	}
+
+	_, _, err := s.send(context.Background(), delivery)
+	if !errors.Is(err, ErrWebhookAddress) {
+		t.Fatalf("send to %s = %v, want %v", server.URL, err, ErrWebhookAddress)
+	}
+	if called {
+		t.Error("receiver on a loopback address was called")
+	}
+}
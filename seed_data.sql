@@ -33,6 +33,10 @@ INSERT INTO stations (name, code, type, latitude, longitude, province, city, add
 
 ('Papua 1 Jayapura', 'PAPUA1', 'KLHK', -2.533333, 140.716667, 'Papua', 'Jayapura', 'Hamadi', true, NOW(), NOW());
 
+-- Stations outside WIB (stations default to Asia/Jakarta)
+UPDATE stations SET timezone = 'Asia/Makassar' WHERE province IN ('Bali', 'Kalimantan Timur', 'Sulawesi Selatan');
+UPDATE stations SET timezone = 'Asia/Jayapura' WHERE province = 'Papua';
+
 -- Sample Air Quality Data (random values for demonstration)
 -- Note: Adjust timestamps as needed
 INSERT INTO air_qualities (station_id, ispu, pm25, pm10, co, no2, o3, so2, timestamp, created_at) VALUES